@@ -0,0 +1,164 @@
+package libvirt
+
+/*
+#include <stdlib.h>
+#include <libvirt/libvirt.h>
+
+virConnectPtr virConnectOpenAuthWrapper(const char *name, int *credtype, unsigned int ncredtype, int callbackID, unsigned int flags);
+*/
+import "C"
+import (
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"unsafe"
+)
+
+// ConnectionOpenFlag defines how a connection should be opened.
+type ConnectionOpenFlag uint32
+
+// Possible values for ConnectionOpenFlag.
+const (
+	ConnOpenDefault   ConnectionOpenFlag = 0
+	ConnOpenReadOnly  ConnectionOpenFlag = C.VIR_CONNECT_RO
+	ConnOpenNoAliases ConnectionOpenFlag = C.VIR_CONNECT_NO_ALIASES
+)
+
+// ConnectionCredentialType defines a type of credential which can be
+// requested while authenticating a connection.
+type ConnectionCredentialType int32
+
+// Possible values for ConnectionCredentialType.
+const (
+	CredUsername     ConnectionCredentialType = C.VIR_CRED_USERNAME
+	CredAuthname     ConnectionCredentialType = C.VIR_CRED_AUTHNAME
+	CredLanguage     ConnectionCredentialType = C.VIR_CRED_LANGUAGE
+	CredCNonce       ConnectionCredentialType = C.VIR_CRED_CNONCE
+	CredPassphrase   ConnectionCredentialType = C.VIR_CRED_PASSPHRASE
+	CredEchoPrompt   ConnectionCredentialType = C.VIR_CRED_ECHOPROMPT
+	CredNoEchoPrompt ConnectionCredentialType = C.VIR_CRED_NOECHOPROMPT
+	CredRealm        ConnectionCredentialType = C.VIR_CRED_REALM
+	CredExternal     ConnectionCredentialType = C.VIR_CRED_EXTERNAL
+)
+
+// ConnectionCredential is a credential requested while authenticating a
+// connection. The authentication callback should fill in "Result".
+type ConnectionCredential struct {
+	Type      ConnectionCredentialType
+	Prompt    string
+	Challenge string
+	DefResult string
+	Result    string
+}
+
+// ConnectionAuthCallback is called when libvirt needs credentials to
+// authenticate a connection. It should fill in the "Result" field of each
+// credential in "creds". If it returns an error, the authentication fails.
+type ConnectionAuthCallback func(creds []*ConnectionCredential) error
+
+// ConnectionAuth describes how a connection should be authenticated: which
+// credential types the application supports and the callback which will
+// provide them.
+type ConnectionAuth struct {
+	CredTypes []ConnectionCredentialType
+	Callback  ConnectionAuthCallback
+}
+
+// ErrInvalidConnectionAuth is returned by "OpenAuth" when the authentication
+// doesn't list any credential type or doesn't have a callback.
+var ErrInvalidConnectionAuth = errors.New("invalid libvirt connection authentication")
+
+// OpenAuth creates a new libvirt connection to the Hypervisor, authenticating
+// it with the credentials provided by "auth". If "auth" is nil, libvirt's
+// default authentication is used, which prompts for the credentials on the
+// console. The URIs are documented at http://libvirt.org/uri.html.
+func OpenAuth(uri string, auth *ConnectionAuth, flags ConnectionOpenFlag, logOutput io.Writer) (Connection, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cURI := C.CString(uri)
+	defer C.free(unsafe.Pointer(cURI))
+
+	logger := newLogger(logOutput)
+
+	if uri == DefaultURI {
+		logger.with(Field{FieldFlags, flags}).Println("opening authenticated connection to the default URI...")
+	} else {
+		logger.with(Field{FieldFlags, flags}).Printf("opening authenticated connection to %v...\n", uri)
+	}
+
+	var cConn C.virConnectPtr
+	if auth == nil {
+		cConn = C.virConnectOpenAuth(cURI, C.virConnectAuthPtrDefault, C.uint(flags))
+	} else {
+		if len(auth.CredTypes) == 0 || auth.Callback == nil {
+			return Connection{}, ErrInvalidConnectionAuth
+		}
+
+		cCredTypes := make([]C.int, len(auth.CredTypes))
+		for i, t := range auth.CredTypes {
+			cCredTypes[i] = C.int(t)
+		}
+
+		callbackID := registerCallback(auth.Callback)
+		defer unregisterCallback(callbackID)
+
+		cConn = C.virConnectOpenAuthWrapper(cURI, &cCredTypes[0], C.uint(len(cCredTypes)), C.int(callbackID), C.uint(flags))
+	}
+
+	if cConn == nil {
+		err := LastError()
+		logger.Printf("an error occurred: %v\n", err)
+		return Connection{}, err
+	}
+
+	logger.Println("connection established")
+
+	conn := Connection{
+		log:        logger,
+		virConnect: cConn,
+	}
+
+	return conn, nil
+}
+
+//export connectAuthCallback
+func connectAuthCallback(cCreds C.virConnectCredentialPtr, cNCreds C.uint, cCallbackID C.int) C.int {
+	callback, ok := lookupCallback(int(cCallbackID)).(ConnectionAuthCallback)
+	if !ok {
+		return -1
+	}
+
+	var cCredsSlice []C.virConnectCredential
+	credsSH := (*reflect.SliceHeader)(unsafe.Pointer(&cCredsSlice))
+	credsSH.Data = uintptr(unsafe.Pointer(cCreds))
+	credsSH.Cap = int(cNCreds)
+	credsSH.Len = int(cNCreds)
+
+	creds := make([]*ConnectionCredential, cNCreds)
+	for i, cCred := range cCredsSlice {
+		creds[i] = &ConnectionCredential{
+			Type:      ConnectionCredentialType(cCred._type),
+			Prompt:    C.GoString(cCred.prompt),
+			Challenge: C.GoString(cCred.challenge),
+			DefResult: C.GoString(cCred.defresult),
+		}
+	}
+
+	if err := callback(creds); err != nil {
+		return -1
+	}
+
+	for i, cred := range creds {
+		if cred.Result == "" {
+			continue
+		}
+
+		// libvirt takes ownership of the result and frees it later
+		cCredsSlice[i].result = C.CString(cred.Result)
+		cCredsSlice[i].resultlen = C.uint(len(cred.Result))
+	}
+
+	return 0
+}
@@ -0,0 +1,25 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+
+int connectAuthCallback(virConnectCredentialPtr cred, unsigned int ncred, int callbackID);
+
+static int connectAuthCallbackHelper(virConnectCredentialPtr cred, unsigned int ncred, void *cbdata) {
+    int *callbackID = cbdata;
+
+    return connectAuthCallback(cred, ncred, *callbackID);
+}
+
+virConnectPtr virConnectOpenAuthWrapper(const char *name, int *credtype, unsigned int ncredtype, int callbackID, unsigned int flags) {
+    virConnectAuth auth = {
+        .credtype = credtype,
+        .ncredtype = ncredtype,
+        .cb = connectAuthCallbackHelper,
+        .cbdata = &callbackID,
+    };
+
+    return virConnectOpenAuth(name, &auth, flags);
+}
+*/
+import "C"
@@ -0,0 +1,110 @@
+package libvirt
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cd1/utils-golang"
+)
+
+const testAuthNodeXML = `
+<node>
+    <auth>
+        <user password="%v">%v</user>
+    </auth>
+</node>`
+
+func TestConnectionOpenAuth(t *testing.T) {
+	username := fmt.Sprintf("user-%v", utils.RandomString())
+	password := fmt.Sprintf("password-%v", utils.RandomString())
+
+	file, err := ioutil.TempFile("", "node-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = fmt.Fprintf(file, testAuthNodeXML, password, username); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	uri := fmt.Sprintf("test://%v", file.Name())
+
+	if _, err = OpenAuth(uri, &ConnectionAuth{}, ConnOpenDefault, testLogOutput); err != ErrInvalidConnectionAuth {
+		t.Errorf("unexpected error when opening a connection with an invalid authentication; got=%v, want=%v", err, ErrInvalidConnectionAuth)
+	}
+
+	credTypes := []ConnectionCredentialType{CredAuthname, CredPassphrase}
+
+	failingAuth := &ConnectionAuth{
+		CredTypes: credTypes,
+		Callback: func(creds []*ConnectionCredential) error {
+			return errors.New("authentication cancelled")
+		},
+	}
+
+	if _, err = OpenAuth(uri, failingAuth, ConnOpenDefault, testLogOutput); err == nil {
+		t.Error("an error was not returned when the authentication callback failed")
+	}
+
+	wrongAuth := &ConnectionAuth{
+		CredTypes: credTypes,
+		Callback: func(creds []*ConnectionCredential) error {
+			for _, cred := range creds {
+				cred.Result = utils.RandomString()
+			}
+
+			return nil
+		},
+	}
+
+	if _, err = OpenAuth(uri, wrongAuth, ConnOpenDefault, testLogOutput); err == nil {
+		t.Error("an error was not returned when opening a connection with wrong credentials")
+	}
+
+	var requested []ConnectionCredentialType
+
+	auth := &ConnectionAuth{
+		CredTypes: credTypes,
+		Callback: func(creds []*ConnectionCredential) error {
+			for _, cred := range creds {
+				requested = append(requested, cred.Type)
+
+				switch cred.Type {
+				case CredAuthname:
+					cred.Result = username
+				case CredPassphrase:
+					cred.Result = password
+				}
+			}
+
+			return nil
+		},
+	}
+
+	conn, err := OpenAuth(uri, auth, ConnOpenReadOnly, testLogOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if len(requested) == 0 {
+		t.Error("the authentication callback was not called")
+	}
+
+	alive, err := conn.IsAlive()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !alive {
+		t.Error("the authenticated libvirt connection was opened but it is not alive")
+	}
+}
@@ -0,0 +1,65 @@
+package libvirt
+
+import "C"
+import (
+	"sync"
+)
+
+// The native callbacks can't hold references to Go values, so every Go
+// callback passed to libvirt is stored here and identified by an integer ID,
+// which is what libvirt actually keeps.
+var (
+	callbacks      = make(map[int]interface{})
+	callbacksMutex sync.RWMutex
+	nextCallbackID int
+)
+
+// registerCallback stores a Go callback and returns an ID which can be passed
+// to libvirt in order to find it again.
+func registerCallback(callback interface{}) int {
+	callbacksMutex.Lock()
+	defer callbacksMutex.Unlock()
+
+	nextCallbackID++
+	id := nextCallbackID
+	callbacks[id] = callback
+
+	return id
+}
+
+// lookupCallback finds a Go callback previously stored with registerCallback.
+// It returns nil if the ID is unknown.
+func lookupCallback(id int) interface{} {
+	callbacksMutex.RLock()
+	defer callbacksMutex.RUnlock()
+
+	return callbacks[id]
+}
+
+// unregisterCallback removes a Go callback previously stored with
+// registerCallback.
+func unregisterCallback(id int) {
+	callbacksMutex.Lock()
+	defer callbacksMutex.Unlock()
+
+	delete(callbacks, id)
+}
+
+// callbackFreer is implemented by the callbacks which need to release
+// resources when libvirt doesn't need them anymore.
+type callbackFreer interface {
+	free()
+}
+
+// freeCallback is called by libvirt when it doesn't need a callback anymore.
+//
+//export freeCallback
+func freeCallback(cCallbackID C.long) {
+	id := int(cCallbackID)
+
+	if f, ok := lookupCallback(id).(callbackFreer); ok {
+		f.free()
+	}
+
+	unregisterCallback(id)
+}
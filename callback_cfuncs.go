@@ -0,0 +1,10 @@
+package libvirt
+
+/*
+void freeCallback(long callbackID);
+
+void freeCallbackHelper(void *opaque) {
+    freeCallback((long)opaque);
+}
+*/
+import "C"
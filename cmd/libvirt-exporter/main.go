@@ -0,0 +1,45 @@
+// Command libvirt-exporter serves metrics about a libvirt host, its domains and
+// its storage pools in the Prometheus text format.
+//
+// Usage:
+//
+//	libvirt-exporter [-uri URI] [-listen ADDRESS] [-path PATH] [-verbose]
+package main
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/cd1/libvirt-golang"
+	"github.com/cd1/libvirt-golang/exporter"
+)
+
+func main() {
+	uri := flag.String("uri", libvirt.DefaultURI, "libvirt connection URI (e.g. qemu:///system, test:///default)")
+	listen := flag.String("listen", ":9177", "address to listen on")
+	path := flag.String("path", "/metrics", "path of the metrics endpoint")
+	verbose := flag.Bool("verbose", false, "print the libvirt log messages")
+	flag.Parse()
+
+	var logOutput io.Writer = ioutil.Discard
+	if *verbose {
+		logOutput = os.Stderr
+	}
+
+	conn, err := libvirt.Open(*uri, libvirt.ReadOnly, logOutput)
+	if err != nil {
+		log.Fatalf("could not connect to libvirt: %v", err)
+	}
+	defer conn.Close()
+
+	http.Handle(*path, exporter.New(conn))
+
+	log.Printf("serving metrics on %v%v", *listen, *path)
+	if err = http.ListenAndServe(*listen, nil); err != nil {
+		log.Fatal(err)
+	}
+}
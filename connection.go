@@ -12,18 +12,20 @@ void emptyErrorFunc(void *userData, virErrorPtr error) {
 */
 import "C"
 import (
+	"context"
+	"encoding/xml"
 	"errors"
 	"io"
 	"io/ioutil"
-	"log"
 	"reflect"
+	"runtime"
 	"unicode/utf8"
 	"unsafe"
 )
 
 // Connection holds a libvirt connection. There are no exported fields.
 type Connection struct {
-	log        *log.Logger
+	log        *logger
 	virConnect C.virConnectPtr
 }
 
@@ -36,6 +38,125 @@ const (
 	ReadOnly
 )
 
+// NodeCPUNumber selects which CPU should be queried for statistics.
+type NodeCPUNumber int32
+
+// Possible values for NodeCPUNumber. Any non-negative value selects a
+// specific CPU.
+const (
+	NodeCPUStatsAllCPUs NodeCPUNumber = C.VIR_NODE_CPU_STATS_ALL_CPUS
+)
+
+// NodeCellNumber selects which NUMA cell should be queried for statistics.
+type NodeCellNumber int32
+
+// Possible values for NodeCellNumber. Any non-negative value selects a
+// specific NUMA cell.
+const (
+	NodeMemoryStatsAllCells NodeCellNumber = C.VIR_NODE_MEMORY_STATS_ALL_CELLS
+)
+
+// NodeInfo holds basic information about the host node.
+type NodeInfo struct {
+	Model   string // the CPU model
+	Memory  uint64 // the memory size, in kiB
+	CPUs    uint32 // the number of active CPUs
+	MHz     uint32 // the expected CPU frequency, 0 if not known
+	Nodes   uint32 // the number of NUMA cells, 1 for unusual NUMA topologies
+	Sockets uint32 // the number of CPU sockets per node
+	Cores   uint32 // the number of cores per socket
+	Threads uint32 // the number of threads per core
+}
+
+// NodeCPUStats holds the CPU statistics of the host node, in nanoseconds
+// (except for Utilization, which is a percentage). Not all hypervisors report
+// every value, so fields may be zero.
+type NodeCPUStats struct {
+	Kernel      uint64
+	User        uint64
+	Idle        uint64
+	IOWait      uint64
+	Intr        uint64
+	Utilization uint64
+}
+
+// NodeMemoryStats holds the memory statistics of the host node, in kiB. Not
+// all hypervisors report every value, so fields may be zero.
+type NodeMemoryStats struct {
+	Total   uint64
+	Free    uint64
+	Buffers uint64
+	Cached  uint64
+}
+
+// CPUCompareFlag defines how two CPUs should be compared.
+type CPUCompareFlag uint32
+
+// Possible values for CPUCompareFlag.
+const (
+	CPUCompareDefault          CPUCompareFlag = 0
+	CPUCompareFailIncompatible CPUCompareFlag = C.VIR_CONNECT_COMPARE_CPU_FAIL_INCOMPATIBLE
+)
+
+// CPUCompareResult is the result of a CPU comparison.
+type CPUCompareResult int32
+
+// Possible values for CPUCompareResult.
+const (
+	CPUCompareError        CPUCompareResult = C.VIR_CPU_COMPARE_ERROR
+	CPUCompareIncompatible CPUCompareResult = C.VIR_CPU_COMPARE_INCOMPATIBLE
+	CPUCompareIdentical    CPUCompareResult = C.VIR_CPU_COMPARE_IDENTICAL
+	CPUCompareSuperset     CPUCompareResult = C.VIR_CPU_COMPARE_SUPERSET
+)
+
+// CPUBaselineFlag defines how a baseline CPU should be computed.
+type CPUBaselineFlag uint32
+
+// Possible values for CPUBaselineFlag.
+const (
+	CPUBaselineDefault        CPUBaselineFlag = 0
+	CPUBaselineExpandFeatures CPUBaselineFlag = C.VIR_CONNECT_BASELINE_CPU_EXPAND_FEATURES
+	CPUBaselineMigratable     CPUBaselineFlag = C.VIR_CONNECT_BASELINE_CPU_MIGRATABLE
+)
+
+// CPU describes a CPU as represented by the <cpu> element of the host
+// capabilities or of a domain XML. It can be used to build the input of
+// CompareCPU and BaselineCPU.
+type CPU struct {
+	XMLName  xml.Name     `xml:"cpu"`
+	Mode     string       `xml:"mode,attr,omitempty"`
+	Match    string       `xml:"match,attr,omitempty"`
+	Arch     string       `xml:"arch,omitempty"`
+	Model    string       `xml:"model,omitempty"`
+	Vendor   string       `xml:"vendor,omitempty"`
+	Topology *CPUTopology `xml:"topology,omitempty"`
+	Features []CPUFeature `xml:"feature"`
+}
+
+// CPUTopology describes the topology of a CPU.
+type CPUTopology struct {
+	Sockets uint32 `xml:"sockets,attr"`
+	Cores   uint32 `xml:"cores,attr"`
+	Threads uint32 `xml:"threads,attr"`
+}
+
+// CPUFeature describes a feature of a CPU. The policy is only meaningful
+// for guest CPUs.
+type CPUFeature struct {
+	Policy string `xml:"policy,attr,omitempty"`
+	Name   string `xml:"name,attr"`
+}
+
+// XML builds the XML description of the CPU.
+func (cpu CPU) XML() (string, error) {
+	data, err := xml.Marshal(cpu)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
 // DefaultURI is the URI chosen by libvirt to establish a default
 // connection, based on the current environment.
 // Check http://libvirt.org/uri.html for more details.
@@ -51,16 +172,13 @@ func init() {
 	C.virSetErrorFunc(nil, C.virErrorFunc(unsafe.Pointer(C.emptyErrorFunc)))
 }
 
-// newLogger creates a logger object to be used across a libvirt
-// connection. It prints the messages to the default error output.
-func newLogger(output io.Writer) *log.Logger {
-	return log.New(output, "libvirt-golang: ", log.LstdFlags)
-}
-
 // Open creates a new libvirt connection to the Hypervisor. The
 // connection mode specifies whether the connection will be read-write
 // or read-only. The URIs are documented at http://libvirt.org/uri.html.
 func Open(uri string, mode ConnectionMode, logOutput io.Writer) (Connection, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
 	cUri := C.CString(uri)
 	defer C.free(unsafe.Pointer(cUri))
 
@@ -98,6 +216,40 @@ func Open(uri string, mode ConnectionMode, logOutput io.Writer) (Connection, err
 	return conn, nil
 }
 
+// OpenContext works like Open, but returns ctx.Err() as soon as "ctx" is done,
+// even if the connection hasn't been established yet. In that case, the
+// connection is closed as soon as it's established.
+func OpenContext(ctx context.Context, uri string, mode ConnectionMode, logOutput io.Writer) (Connection, error) {
+	if err := ctx.Err(); err != nil {
+		return Connection{}, err
+	}
+
+	type result struct {
+		conn Connection
+		err  error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		conn, err := Open(uri, mode, logOutput)
+		done <- result{conn, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.err == nil {
+				r.conn.Close()
+			}
+		}()
+
+		return Connection{}, ctx.Err()
+	}
+}
+
 // OpenDefault creates a new read-write libvirt connection to the
 // hypervisor using the default URI.
 func OpenDefault() (Connection, error) {
@@ -119,13 +271,18 @@ func OpenDefault() (Connection, error) {
 // connection, but the application should not try to further use a connection
 // after the Close that matches the initial open.
 func (conn Connection) Close() (int32, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("closing connection...")
 	cRet := C.virConnectClose(conn.virConnect)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -136,6 +293,11 @@ func (conn Connection) Close() (int32, error) {
 
 // Version gets the version level of the Hypervisor running.
 func (conn Connection) Version() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	var cVersion C.ulong
 	conn.log.Println("reading hypervisor version...")
 	cRet := C.virConnectGetVersion(conn.virConnect, &cVersion)
@@ -143,7 +305,7 @@ func (conn Connection) Version() (uint64, error) {
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -156,6 +318,11 @@ func (conn Connection) Version() (uint64, error) {
 // LibVersion provides the version of libvirt used by the daemon running on
 // the host.
 func (conn Connection) LibVersion() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	var cVersion C.ulong
 	conn.log.Println("reading libvirt version...")
 	cRet := C.virConnectGetLibVersion(conn.virConnect, &cVersion)
@@ -163,7 +330,7 @@ func (conn Connection) LibVersion() (uint64, error) {
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -177,13 +344,18 @@ func (conn Connection) LibVersion() (uint64, error) {
 // If an error occurs, the function will also return "false" and the error
 // message will be written to the log.
 func (conn Connection) IsAlive() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("checking whether connection is alive...")
 	cRet := C.virConnectIsAlive(conn.virConnect)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -202,13 +374,18 @@ func (conn Connection) IsAlive() (bool, error) {
 // If an error occurs, the function will also return "false" and the error
 // message will be written to the log.
 func (conn Connection) IsEncrypted() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("checking whether connection is encrypted...")
 	cRet := C.virConnectIsEncrypted(conn.virConnect)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -227,13 +404,18 @@ func (conn Connection) IsEncrypted() (bool, error) {
 // If an error occurs, the function will also return "false" and the error
 // message will be written to the log.
 func (conn Connection) IsSecure() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("checking whether connection is secure...")
 	cRet := C.virConnectIsSecure(conn.virConnect)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -250,11 +432,16 @@ func (conn Connection) IsSecure() (bool, error) {
 
 // Capabilities provides capabilities of the hypervisor/driver.
 func (conn Connection) Capabilities() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("reading connection capabilities...")
 	cCap := C.virConnectGetCapabilities(conn.virConnect)
 	if cCap == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cCap))
@@ -270,11 +457,16 @@ func (conn Connection) Capabilities() (string, error) {
 // to a fully-qualified domain name via getaddrinfo). If we are connected to a
 // remote system, then this returns the hostname of the remote system.
 func (conn Connection) Hostname() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("reading system hostname...")
 	cHostname := C.virConnectGetHostname(conn.virConnect)
 	if cHostname == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cHostname))
@@ -290,11 +482,16 @@ func (conn Connection) Hostname() (string, error) {
 // of a domain XML. This information is generally available only for
 // hypervisors running with root privileges.
 func (conn Connection) Sysinfo() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("reading system info...")
 	cSysinfo := C.virConnectGetSysinfo(conn.virConnect, 0)
 	if cSysinfo == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cSysinfo))
@@ -310,11 +507,16 @@ func (conn Connection) Sysinfo() (string, error) {
 // acceleration is present. For more details about the hypervisor, use
 // Capabilities.
 func (conn Connection) Type() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("reading hypervisor driver name...")
 	cType := C.virConnectGetType(conn.virConnect)
 	if cType == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -330,11 +532,16 @@ func (conn Connection) Type() (string, error) {
 // then the driver will return a non-NULL URI which can be used to connect tos
 // the same hypervisor later.
 func (conn Connection) URI() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("reading connection URI...")
 	cURI := C.virConnectGetURI(conn.virConnect)
 	if cURI == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cURI))
@@ -349,12 +556,17 @@ func (conn Connection) URI() (string, error) {
 // the reference count, once the caller no longer needs the reference to
 // this object.
 func (conn Connection) Ref() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Println("incrementing connection's reference count...")
 	cRet := C.virConnectRef(conn.virConnect)
 	ret := int32(cRet)
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -366,6 +578,11 @@ func (conn Connection) Ref() error {
 // CPUModelNames gets the list of supported CPU models for a
 // specific architecture.
 func (conn Connection) CPUModelNames(arch string) ([]string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cArch := C.CString(arch)
 	defer C.free(unsafe.Pointer(cArch))
 
@@ -378,7 +595,7 @@ func (conn Connection) CPUModelNames(arch string) ([]string, error) {
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return nil, err
 	}
 	defer C.free(unsafe.Pointer(modelsSH.Data))
@@ -397,10 +614,170 @@ func (conn Connection) CPUModelNames(arch string) ([]string, error) {
 	return models, nil
 }
 
+// HostCPU extracts the description of the host CPU from the hypervisor
+// capabilities.
+func (conn Connection) HostCPU() (CPU, error) {
+	capXML, err := conn.Capabilities()
+	if err != nil {
+		return CPU{}, err
+	}
+
+	var cap struct {
+		Host struct {
+			CPU CPU `xml:"cpu"`
+		} `xml:"host"`
+	}
+
+	conn.log.Println("parsing host CPU from capabilities...")
+	if err = xml.Unmarshal([]byte(capXML), &cap); err != nil {
+		conn.log.error(err)
+		return CPU{}, err
+	}
+
+	conn.log.Printf("host CPU model: %v\n", cap.Host.CPU.Model)
+
+	return cap.Host.CPU, nil
+}
+
+// CompareCPU compares the given CPU description with the host CPU. If "flags"
+// includes CPUCompareFailIncompatible, an incompatible CPU is reported as an
+// error (with code ErrCPUIncompatible) describing why the CPUs are
+// incompatible; the result is still CPUCompareIncompatible in that case.
+func (conn Connection) CompareCPU(xml string, flags CPUCompareFlag) (CPUCompareResult, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	cXML := C.CString(xml)
+	defer C.free(unsafe.Pointer(cXML))
+
+	conn.log.with(Field{FieldFlags, flags}).Println("comparing CPU with host CPU...")
+	cRet := C.virConnectCompareCPU(conn.virConnect, cXML, C.uint(flags))
+	result := CPUCompareResult(cRet)
+
+	if result == CPUCompareError {
+		err := LastError()
+		call.error(err)
+
+		if err != nil && err.Code == ErrCPUIncompatible {
+			return CPUCompareIncompatible, err
+		}
+
+		return CPUCompareError, err
+	}
+
+	conn.log.Printf("CPU comparison result: %v\n", result)
+
+	return result, nil
+}
+
+// BaselineCPU computes the most feature-rich CPU which is compatible with all
+// given CPUs, described by "xmls". If "flags" includes CPUBaselineExpandFeatures,
+// the features which are part of the CPU model are listed explicitly; if it
+// includes CPUBaselineMigratable, features that block migration are removed.
+func (conn Connection) BaselineCPU(xmls []string, flags CPUBaselineFlag) (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	cXMLs := make([]*C.char, len(xmls))
+	for i, x := range xmls {
+		cXMLs[i] = C.CString(x)
+		defer C.free(unsafe.Pointer(cXMLs[i]))
+	}
+
+	var cXMLsPtr **C.char
+	if len(cXMLs) > 0 {
+		cXMLsPtr = &cXMLs[0]
+	}
+
+	conn.log.with(Field{FieldFlags, flags}).Printf("computing baseline CPU of %v CPUs...\n", len(xmls))
+	cCPU := C.virConnectBaselineCPU(conn.virConnect, cXMLsPtr, C.uint(len(xmls)), C.uint(flags))
+
+	if cCPU == nil {
+		err := LastError()
+		call.error(err)
+		return "", err
+	}
+	defer C.free(unsafe.Pointer(cCPU))
+
+	cpu := C.GoString(cCPU)
+	conn.log.Printf("baseline CPU XML length: %v runes\n", utf8.RuneCountInString(cpu))
+
+	return cpu, nil
+}
+
+// BaselineHypervisorCPU computes the most feature-rich CPU which is compatible
+// with all given CPUs, described by "xmls", and can be provided by the
+// specified hypervisor. Any of "emulator", "arch", "machine" and "virtType"
+// may be empty, in which case the hypervisor's default is used. See
+// BaselineCPU for the meaning of "flags".
+func (conn Connection) BaselineHypervisorCPU(emulator string, arch string, machine string, virtType string, xmls []string, flags CPUBaselineFlag) (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	var cEmulator, cArch, cMachine, cVirtType *C.char
+
+	if emulator != "" {
+		cEmulator = C.CString(emulator)
+		defer C.free(unsafe.Pointer(cEmulator))
+	}
+
+	if arch != "" {
+		cArch = C.CString(arch)
+		defer C.free(unsafe.Pointer(cArch))
+	}
+
+	if machine != "" {
+		cMachine = C.CString(machine)
+		defer C.free(unsafe.Pointer(cMachine))
+	}
+
+	if virtType != "" {
+		cVirtType = C.CString(virtType)
+		defer C.free(unsafe.Pointer(cVirtType))
+	}
+
+	cXMLs := make([]*C.char, len(xmls))
+	for i, x := range xmls {
+		cXMLs[i] = C.CString(x)
+		defer C.free(unsafe.Pointer(cXMLs[i]))
+	}
+
+	var cXMLsPtr **C.char
+	if len(cXMLs) > 0 {
+		cXMLsPtr = &cXMLs[0]
+	}
+
+	conn.log.with(Field{FieldFlags, flags}).Printf("computing baseline hypervisor CPU of %v CPUs...\n", len(xmls))
+	cCPU := C.virConnectBaselineHypervisorCPU(conn.virConnect, cEmulator, cArch, cMachine, cVirtType, cXMLsPtr, C.uint(len(xmls)), C.uint(flags))
+
+	if cCPU == nil {
+		err := LastError()
+		call.error(err)
+		return "", err
+	}
+	defer C.free(unsafe.Pointer(cCPU))
+
+	cpu := C.GoString(cCPU)
+	conn.log.Printf("baseline CPU XML length: %v runes\n", utf8.RuneCountInString(cpu))
+
+	return cpu, nil
+}
+
 // MaxVCPUs provides the maximum number of virtual CPUs supported for a guest
 // VM of a specific type. The 'type' parameter here corresponds to the 'type'
 // attribute in the <domain> element of the XML
 func (conn Connection) MaxVCPUs(typ string) (int32, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cTyp := C.CString(typ)
 	defer C.free(unsafe.Pointer(cTyp))
 
@@ -410,7 +787,7 @@ func (conn Connection) MaxVCPUs(typ string) (int32, error) {
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -422,16 +799,21 @@ func (conn Connection) MaxVCPUs(typ string) (int32, error) {
 // ListDomains collects a possibly-filtered list of all domains, and return an
 // array of information for each.
 func (conn Connection) ListDomains(flags DomainListFlag) ([]Domain, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	var cDomains []C.virDomainPtr
 	domainsSH := (*reflect.SliceHeader)(unsafe.Pointer(&cDomains))
 
-	conn.log.Printf("reading domains (flags = %v)...\n", flags)
+	conn.log.with(Field{FieldFlags, flags}).Println("reading domains...")
 	cRet := C.virConnectListAllDomains(conn.virConnect, (**C.virDomainPtr)(unsafe.Pointer(&domainsSH.Data)), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return nil, err
 	}
 	defer C.free(unsafe.Pointer(domainsSH.Data))
@@ -442,8 +824,9 @@ func (conn Connection) ListDomains(flags DomainListFlag) ([]Domain, error) {
 	domains := make([]Domain, ret)
 	for i := range domains {
 		domains[i] = Domain{
-			log:       conn.log,
+			log:       conn.log.with(Field{FieldObject, objectDomain}),
 			virDomain: cDomains[i],
+			ref:       newDomainRef(cDomains[i]),
 		}
 	}
 
@@ -458,22 +841,28 @@ func (conn Connection) ListDomains(flags DomainListFlag) ([]Domain, error) {
 // definition will disappear when it is destroyed, or if the host is restarted
 // (see Domain.Define() to define persistent domains).
 func (conn Connection) CreateDomain(xml string, flags DomainCreateFlag) (Domain, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(xml)
 	defer C.free(unsafe.Pointer(cXML))
 
-	conn.log.Printf("creating domain (flags = %v)...\n", flags)
+	conn.log.with(Field{FieldFlags, flags}).Println("creating domain...")
 	cDomain := C.virDomainCreateXML(conn.virConnect, cXML, C.uint(flags))
 	if cDomain == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Domain{}, err
 	}
 
 	conn.log.Println("domain created")
 
 	dom := Domain{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectDomain}),
 		virDomain: cDomain,
+		ref:       newDomainRef(cDomain),
 	}
 
 	return dom, nil
@@ -483,6 +872,11 @@ func (conn Connection) CreateDomain(xml string, flags DomainCreateFlag) (Domain,
 // persistent, until explicitly undefined with Domain.Undefine(). A previous
 // definition for this domain would be overridden if it already exists.
 func (conn Connection) DefineDomain(xml string) (Domain, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(xml)
 	defer C.free(unsafe.Pointer(cXML))
 
@@ -490,15 +884,16 @@ func (conn Connection) DefineDomain(xml string) (Domain, error) {
 	cDomain := C.virDomainDefineXML(conn.virConnect, cXML)
 	if cDomain == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Domain{}, err
 	}
 
 	conn.log.Println("domain defined")
 
 	dom := Domain{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectDomain}),
 		virDomain: cDomain,
+		ref:       newDomainRef(cDomain),
 	}
 
 	return dom, nil
@@ -508,19 +903,25 @@ func (conn Connection) DefineDomain(xml string) (Domain, error) {
 // Note that this won't work for inactive domains which have an ID of -1, in
 // that case a lookup based on the Name or UUID need to be done instead.
 func (conn Connection) LookupDomainByID(id uint32) (Domain, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	conn.log.Printf("looking up domain with ID = %v...\n", id)
 	cDomain := C.virDomainLookupByID(conn.virConnect, C.int(id))
 	if cDomain == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Domain{}, err
 	}
 
 	conn.log.Println("domain found")
 
 	dom := Domain{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectDomain}),
 		virDomain: cDomain,
+		ref:       newDomainRef(cDomain),
 	}
 
 	return dom, nil
@@ -529,6 +930,11 @@ func (conn Connection) LookupDomainByID(id uint32) (Domain, error) {
 // LookupDomainByName tries to lookup a domain on the given hypervisor based on
 // its name.
 func (conn Connection) LookupDomainByName(name string) (Domain, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
 
@@ -536,15 +942,16 @@ func (conn Connection) LookupDomainByName(name string) (Domain, error) {
 	cDomain := C.virDomainLookupByName(conn.virConnect, cName)
 	if cDomain == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Domain{}, err
 	}
 
 	conn.log.Println("domain found")
 
 	dom := Domain{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectDomain}, Field{FieldName, name}),
 		virDomain: cDomain,
+		ref:       newDomainRef(cDomain),
 	}
 
 	return dom, nil
@@ -553,6 +960,11 @@ func (conn Connection) LookupDomainByName(name string) (Domain, error) {
 // LookupDomainByUUID tries to lookup a domain on the given hypervisor based on
 // its UUID.
 func (conn Connection) LookupDomainByUUID(uuid string) (Domain, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cUUID := C.CString(uuid)
 	defer C.free(unsafe.Pointer(cUUID))
 
@@ -560,15 +972,16 @@ func (conn Connection) LookupDomainByUUID(uuid string) (Domain, error) {
 	cDomain := C.virDomainLookupByUUIDString(conn.virConnect, cUUID)
 	if cDomain == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Domain{}, err
 	}
 
 	conn.log.Println("domain found")
 
 	dom := Domain{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectDomain}, Field{FieldUUID, uuid}),
 		virDomain: cDomain,
+		ref:       newDomainRef(cDomain),
 	}
 
 	return dom, nil
@@ -576,6 +989,11 @@ func (conn Connection) LookupDomainByUUID(uuid string) (Domain, error) {
 
 // RestoreDomain restores a domain saved to disk by Save().
 func (conn Connection) RestoreDomain(from string, xml string, flags DomainSaveFlag) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cFrom := C.CString(from)
 	defer C.free(unsafe.Pointer(cFrom))
 
@@ -587,13 +1005,13 @@ func (conn Connection) RestoreDomain(from string, xml string, flags DomainSaveFl
 		cXML = nil
 	}
 
-	conn.log.Printf("restoring domain from file %v (flags = %v)...\n", from, flags)
+	conn.log.with(Field{FieldFlags, flags}).Printf("restoring domain from file %v...\n", from)
 	cRet := C.virDomainRestoreFlags(conn.virConnect, cFrom, cXML, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -616,16 +1034,21 @@ func (conn Connection) RestoreDomain(from string, xml string, flags DomainSaveFl
 // libvirt nor to any other node. Flag SecListNoPrivate selects
 // non-private secrets.
 func (conn Connection) ListSecrets(flags SecretListFlag) ([]Secret, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	var cSecrets []C.virSecretPtr
 	secretsSH := (*reflect.SliceHeader)(unsafe.Pointer(&cSecrets))
 
-	conn.log.Printf("reading secrets (flags = %v)...\n", flags)
+	conn.log.with(Field{FieldFlags, flags}).Println("reading secrets...")
 	cRet := C.virConnectListAllSecrets(conn.virConnect, (**C.virSecretPtr)(unsafe.Pointer(&secretsSH.Data)), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return nil, err
 	}
 	defer C.free(unsafe.Pointer(secretsSH.Data))
@@ -636,8 +1059,9 @@ func (conn Connection) ListSecrets(flags SecretListFlag) ([]Secret, error) {
 	secrets := make([]Secret, ret)
 	for i := range secrets {
 		secrets[i] = Secret{
-			log:       conn.log,
+			log:       conn.log.with(Field{FieldObject, objectSecret}),
 			virSecret: cSecrets[i],
+			ref:       newSecretRef(cSecrets[i]),
 		}
 	}
 
@@ -654,6 +1078,11 @@ func (conn Connection) ListSecrets(flags SecretListFlag) ([]Secret, error) {
 // "Free" should be used to free the resources after the secret object is no
 // longer needed.
 func (conn Connection) DefineSecret(xml string) (Secret, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(xml)
 	defer C.free(unsafe.Pointer(cXML))
 
@@ -662,15 +1091,16 @@ func (conn Connection) DefineSecret(xml string) (Secret, error) {
 
 	if cSec == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Secret{}, err
 	}
 
 	conn.log.Println("secret defined")
 
 	sec := Secret{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectSecret}),
 		virSecret: cSec,
+		ref:       newSecretRef(cSec),
 	}
 
 	return sec, nil
@@ -681,6 +1111,11 @@ func (conn Connection) DefineSecret(xml string) (Secret, error) {
 // "Free" should be used to free the resources after the secret object is no
 // longer needed.
 func (conn Connection) LookupSecretByUUID(uuid string) (Secret, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cUUID := C.CString(uuid)
 	defer C.free(unsafe.Pointer(cUUID))
 
@@ -688,12 +1123,14 @@ func (conn Connection) LookupSecretByUUID(uuid string) (Secret, error) {
 
 	if cSecret == nil {
 		err := LastError()
+		call.record(err)
 		return Secret{}, err
 	}
 
 	secret := Secret{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectSecret}, Field{FieldUUID, uuid}),
 		virSecret: cSecret,
+		ref:       newSecretRef(cSecret),
 	}
 
 	return secret, nil
@@ -705,6 +1142,11 @@ func (conn Connection) LookupSecretByUUID(uuid string) (Secret, error) {
 // "Free" should be used to free the resources after the secret object is no
 // longer needed.
 func (conn Connection) LookupSecretByUsage(usageType SecretUsageType, usageID string) (Secret, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cUsageType := C.int(usageType)
 	cUsageID := C.CString(usageID)
 	defer C.free(unsafe.Pointer(cUsageID))
@@ -713,12 +1155,14 @@ func (conn Connection) LookupSecretByUsage(usageType SecretUsageType, usageID st
 
 	if cSecret == nil {
 		err := LastError()
+		call.record(err)
 		return Secret{}, err
 	}
 
 	secret := Secret{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectSecret}),
 		virSecret: cSecret,
+		ref:       newSecretRef(cSecret),
 	}
 
 	return secret, nil
@@ -731,20 +1175,28 @@ func (conn Connection) LookupSecretByUsage(usageType SecretUsageType, usageID st
 // is an instance of the storage pool's source element specifying where to look
 // for the pools.
 // "source" is not required for some types (e.g., those querying local storage
-// resources only)
+// resources only); it's passed as NULL to libvirt when it's empty.
 func (conn Connection) FindStoragePoolSources(typ string, source string) (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cType := C.CString(typ)
 	defer C.free(unsafe.Pointer(cType))
 
-	cSource := C.CString(source)
-	defer C.free(unsafe.Pointer(cSource))
+	var cSource *C.char
+	if source != "" {
+		cSource = C.CString(source)
+		defer C.free(unsafe.Pointer(cSource))
+	}
 
 	conn.log.Printf("finding storage pool sources (type = %v)...\n", typ)
 	cSources := C.virConnectFindStoragePoolSources(conn.virConnect, cType, cSource, 0)
 
 	if cSources == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cSources))
@@ -774,16 +1226,21 @@ func (conn Connection) FindStoragePoolSources(typ string, source string) (string
 // PoolListDisk, PoolListISCSI, PoolListSCSI, PoolListMPath, PoolListRBD,
 // PoolListSheepdog.
 func (conn Connection) ListStoragePools(flags StoragePoolListFlag) ([]StoragePool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	var cStoragePools []C.virStoragePoolPtr
 	cStoragePoolsSH := (*reflect.SliceHeader)(unsafe.Pointer(&cStoragePools))
 
-	conn.log.Printf("reading storage pools (flags = %v)...\n", flags)
+	conn.log.with(Field{FieldFlags, flags}).Println("reading storage pools...")
 	cRet := C.virConnectListAllStoragePools(conn.virConnect, (**C.virStoragePoolPtr)(unsafe.Pointer(&cStoragePoolsSH.Data)), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return nil, err
 	}
 	defer C.free(unsafe.Pointer(cStoragePoolsSH.Data))
@@ -794,8 +1251,9 @@ func (conn Connection) ListStoragePools(flags StoragePoolListFlag) ([]StoragePoo
 	storagePools := make([]StoragePool, ret)
 	for i, cPool := range cStoragePools {
 		storagePools[i] = StoragePool{
-			log:            conn.log,
+			log:            conn.log.with(Field{FieldObject, objectStoragePool}),
 			virStoragePool: cPool,
+			ref:            newStoragePoolRef(cPool),
 		}
 	}
 
@@ -809,6 +1267,11 @@ func (conn Connection) ListStoragePools(flags StoragePoolListFlag) ([]StoragePoo
 // "Free" should be used to free the resources after the storage pool object is
 // no longer needed.
 func (conn Connection) DefineStoragePool(xml string) (StoragePool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(xml)
 	defer C.free(unsafe.Pointer(cXML))
 
@@ -817,13 +1280,14 @@ func (conn Connection) DefineStoragePool(xml string) (StoragePool, error) {
 
 	if cPool == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StoragePool{}, err
 	}
 
 	pool := StoragePool{
-		log:            conn.log,
+		log:            conn.log.with(Field{FieldObject, objectStoragePool}),
 		virStoragePool: cPool,
+		ref:            newStoragePoolRef(cPool),
 	}
 
 	conn.log.Println("pool defined")
@@ -837,6 +1301,11 @@ func (conn Connection) DefineStoragePool(xml string) (StoragePool, error) {
 // "Free" should be used to free the resources after the storage pool object is
 // no longer needed.
 func (conn Connection) CreateStoragePool(xml string) (StoragePool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(xml)
 	defer C.free(unsafe.Pointer(cXML))
 
@@ -845,13 +1314,14 @@ func (conn Connection) CreateStoragePool(xml string) (StoragePool, error) {
 
 	if cPool == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StoragePool{}, err
 	}
 
 	pool := StoragePool{
-		log:            conn.log,
+		log:            conn.log.with(Field{FieldObject, objectStoragePool}),
 		virStoragePool: cPool,
+		ref:            newStoragePoolRef(cPool),
 	}
 
 	conn.log.Println("pool created")
@@ -863,6 +1333,11 @@ func (conn Connection) CreateStoragePool(xml string) (StoragePool, error) {
 // "Free" should be used to free the resources after the storage pool object is
 // no longer needed.
 func (conn Connection) LookupStoragePoolByName(name string) (StoragePool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
 
@@ -871,15 +1346,16 @@ func (conn Connection) LookupStoragePoolByName(name string) (StoragePool, error)
 
 	if cPool == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StoragePool{}, err
 	}
 
 	conn.log.Println("pool found")
 
 	pool := StoragePool{
-		log:            conn.log,
+		log:            conn.log.with(Field{FieldObject, objectStoragePool}, Field{FieldName, name}),
 		virStoragePool: cPool,
+		ref:            newStoragePoolRef(cPool),
 	}
 
 	return pool, nil
@@ -890,6 +1366,11 @@ func (conn Connection) LookupStoragePoolByName(name string) (StoragePool, error)
 // "Free" should be used to free the resources after the storage pool object is
 // no longer needed.
 func (conn Connection) LookupStoragePoolByUUID(uuid string) (StoragePool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cUUID := C.CString(uuid)
 	defer C.free(unsafe.Pointer(cUUID))
 
@@ -898,15 +1379,16 @@ func (conn Connection) LookupStoragePoolByUUID(uuid string) (StoragePool, error)
 
 	if cPool == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StoragePool{}, err
 	}
 
 	conn.log.Println("pool found")
 
 	pool := StoragePool{
-		log:            conn.log,
+		log:            conn.log.with(Field{FieldObject, objectStoragePool}, Field{FieldUUID, uuid}),
 		virStoragePool: cPool,
+		ref:            newStoragePoolRef(cPool),
 	}
 
 	return pool, nil
@@ -917,6 +1399,11 @@ func (conn Connection) LookupStoragePoolByUUID(uuid string) (StoragePool, error)
 //"Free" should be used to free the resources after the storage volume object is
 // no longer needed.
 func (conn Connection) LookupStorageVolumeByPath(path string) (StorageVolume, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cPath := C.CString(path)
 	defer C.free(unsafe.Pointer(cPath))
 
@@ -925,15 +1412,16 @@ func (conn Connection) LookupStorageVolumeByPath(path string) (StorageVolume, er
 
 	if cVol == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StorageVolume{}, err
 	}
 
 	conn.log.Println("volume found")
 
 	vol := StorageVolume{
-		log:           conn.log,
+		log:           conn.log.with(Field{FieldObject, objectStorageVolume}),
 		virStorageVol: cVol,
+		ref:           newStorageVolumeRef(cVol),
 	}
 
 	return vol, nil
@@ -944,6 +1432,11 @@ func (conn Connection) LookupStorageVolumeByPath(path string) (StorageVolume, er
 // "Free" should be used to free the resources after the storage volume object
 // is no longer needed.
 func (conn Connection) LookupStorageVolumeByKey(key string) (StorageVolume, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	cKey := C.CString(key)
 	defer C.free(unsafe.Pointer(cKey))
 
@@ -952,15 +1445,16 @@ func (conn Connection) LookupStorageVolumeByKey(key string) (StorageVolume, erro
 
 	if cVol == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StorageVolume{}, err
 	}
 
 	conn.log.Println("volume found")
 
 	vol := StorageVolume{
-		log:           conn.log,
+		log:           conn.log.with(Field{FieldObject, objectStorageVolume}),
 		virStorageVol: cVol,
+		ref:           newStorageVolumeRef(cVol),
 	}
 
 	return vol, nil
@@ -974,20 +1468,26 @@ func (conn Connection) LookupStorageVolumeByKey(key string) (StorageVolume, erro
 // If a non-blocking data stream is required passed StrNonBlock for flags,
 // otherwise pass StrDefault.
 func (conn Connection) NewStream(flags StreamFlag) (Stream, error) {
-	conn.log.Printf("creating stream (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	conn.log.with(Field{FieldFlags, flags}).Println("creating stream...")
 	cStream := C.virStreamNew(conn.virConnect, C.uint(flags))
 
 	if cStream == nil {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Stream{}, err
 	}
 
 	conn.log.Println("stream created")
 
 	stream := Stream{
-		log:       conn.log,
+		log:       conn.log.with(Field{FieldObject, objectStream}),
 		virStream: cStream,
+		ref:       newStreamRef(cStream),
 	}
 
 	return stream, nil
@@ -1003,16 +1503,21 @@ func (conn Connection) NewStream(flags StreamFlag) (Stream, error) {
 // The only group of "flags" is IfaceListActive (up) and
 // IfaceListInactive (down) to filter the interfaces by state.
 func (conn Connection) ListInterfaces(flags InterfaceListFlag) ([]Interface, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
 	var cInterfaces []C.virInterfacePtr
 	cInterfacesSH := (*reflect.SliceHeader)(unsafe.Pointer(&cInterfaces))
 
-	conn.log.Printf("reading interfaces (flags = %v)...\n", flags)
+	conn.log.with(Field{FieldFlags, flags}).Println("reading interfaces...")
 	cRet := C.virConnectListAllInterfaces(conn.virConnect, (**C.virInterfacePtr)(unsafe.Pointer(&cInterfacesSH.Data)), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		conn.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return nil, err
 	}
 	defer C.free(unsafe.Pointer(cInterfacesSH.Data))
@@ -1023,8 +1528,9 @@ func (conn Connection) ListInterfaces(flags InterfaceListFlag) ([]Interface, err
 	interfaces := make([]Interface, ret)
 	for i, cIface := range cInterfaces {
 		interfaces[i] = Interface{
-			log:          conn.log,
+			log:          conn.log.with(Field{FieldObject, objectInterface}),
 			virInterface: cIface,
+			ref:          newInterfaceRef(cIface),
 		}
 	}
 
@@ -1032,3 +1538,295 @@ func (conn Connection) ListInterfaces(flags InterfaceListFlag) ([]Interface, err
 
 	return interfaces, nil
 }
+
+// NodeInfo extracts hardware information about the node (the host running the
+// hypervisor).
+func (conn Connection) NodeInfo() (NodeInfo, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	var cInfo C.virNodeInfo
+
+	conn.log.Println("reading node info...")
+	cRet := C.virNodeGetInfo(conn.virConnect, &cInfo)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return NodeInfo{}, err
+	}
+
+	info := NodeInfo{
+		Model:   C.GoString(&cInfo.model[0]),
+		Memory:  uint64(cInfo.memory),
+		CPUs:    uint32(cInfo.cpus),
+		MHz:     uint32(cInfo.mhz),
+		Nodes:   uint32(cInfo.nodes),
+		Sockets: uint32(cInfo.sockets),
+		Cores:   uint32(cInfo.cores),
+		Threads: uint32(cInfo.threads),
+	}
+
+	conn.log.Printf("node info: %+v\n", info)
+
+	return info, nil
+}
+
+// NodeCPUStats provides the CPU statistics of the node. If "cpu" is
+// NodeCPUStatsAllCPUs, the statistics are cumulative for all CPUs in the host;
+// otherwise, only the specified CPU is queried.
+func (conn Connection) NodeCPUStats(cpu NodeCPUNumber) (NodeCPUStats, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	var cNParams C.int
+
+	conn.log.Printf("reading node CPU stats (cpu = %v)...\n", cpu)
+	cRet := C.virNodeGetCPUStats(conn.virConnect, C.int(cpu), nil, &cNParams, 0)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return NodeCPUStats{}, err
+	}
+
+	var stats NodeCPUStats
+
+	if cNParams == 0 {
+		conn.log.Println("no node CPU stats available")
+		return stats, nil
+	}
+
+	cParams := make([]C.virNodeCPUStats, cNParams)
+	cRet = C.virNodeGetCPUStats(conn.virConnect, C.int(cpu), &cParams[0], &cNParams, 0)
+	ret = int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return NodeCPUStats{}, err
+	}
+
+	for _, cParam := range cParams[:cNParams] {
+		value := uint64(cParam.value)
+
+		switch C.GoString(&cParam.field[0]) {
+		case C.VIR_NODE_CPU_STATS_KERNEL:
+			stats.Kernel = value
+		case C.VIR_NODE_CPU_STATS_USER:
+			stats.User = value
+		case C.VIR_NODE_CPU_STATS_IDLE:
+			stats.Idle = value
+		case C.VIR_NODE_CPU_STATS_IOWAIT:
+			stats.IOWait = value
+		case C.VIR_NODE_CPU_STATS_INTR:
+			stats.Intr = value
+		case C.VIR_NODE_CPU_STATS_UTILIZATION:
+			stats.Utilization = value
+		}
+	}
+
+	conn.log.Printf("node CPU stats: %+v\n", stats)
+
+	return stats, nil
+}
+
+// NodeMemoryStats provides the memory statistics of the node. If "cell" is
+// NodeMemoryStatsAllCells, the statistics are cumulative for all NUMA cells in
+// the host; otherwise, only the specified cell is queried.
+func (conn Connection) NodeMemoryStats(cell NodeCellNumber) (NodeMemoryStats, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	var cNParams C.int
+
+	conn.log.Printf("reading node memory stats (cell = %v)...\n", cell)
+	cRet := C.virNodeGetMemoryStats(conn.virConnect, C.int(cell), nil, &cNParams, 0)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return NodeMemoryStats{}, err
+	}
+
+	var stats NodeMemoryStats
+
+	if cNParams == 0 {
+		conn.log.Println("no node memory stats available")
+		return stats, nil
+	}
+
+	cParams := make([]C.virNodeMemoryStats, cNParams)
+	cRet = C.virNodeGetMemoryStats(conn.virConnect, C.int(cell), &cParams[0], &cNParams, 0)
+	ret = int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return NodeMemoryStats{}, err
+	}
+
+	for _, cParam := range cParams[:cNParams] {
+		value := uint64(cParam.value)
+
+		switch C.GoString(&cParam.field[0]) {
+		case C.VIR_NODE_MEMORY_STATS_TOTAL:
+			stats.Total = value
+		case C.VIR_NODE_MEMORY_STATS_FREE:
+			stats.Free = value
+		case C.VIR_NODE_MEMORY_STATS_BUFFERS:
+			stats.Buffers = value
+		case C.VIR_NODE_MEMORY_STATS_CACHED:
+			stats.Cached = value
+		}
+	}
+
+	conn.log.Printf("node memory stats: %+v\n", stats)
+
+	return stats, nil
+}
+
+// NodeFreeMemory provides the free memory available on the node, in bytes.
+// Most libvirt APIs provide memory sizes in kiB, but this function returns
+// in bytes.
+func (conn Connection) NodeFreeMemory() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	conn.log.Println("reading node free memory...")
+	cRet := C.virNodeGetFreeMemory(conn.virConnect)
+	ret := uint64(cRet)
+
+	if ret == 0 {
+		err := LastError()
+		call.error(err)
+		return 0, err
+	}
+
+	conn.log.Printf("node free memory: %v bytes\n", ret)
+
+	return ret, nil
+}
+
+// NodeCellsFreeMemory provides the free memory available on each NUMA cell of
+// the node, in bytes, starting from cell "start" and reading up to "maxCells"
+// cells. The returned slice may be shorter than "maxCells" if there are fewer
+// cells available.
+func (conn Connection) NodeCellsFreeMemory(start int32, maxCells int32) ([]uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	if maxCells <= 0 {
+		return []uint64{}, nil
+	}
+
+	cFreeMems := make([]C.ulonglong, maxCells)
+
+	conn.log.Printf("reading node cells free memory (start = %v, max = %v)...\n", start, maxCells)
+	cRet := C.virNodeGetCellsFreeMemory(conn.virConnect, &cFreeMems[0], C.int(start), C.int(maxCells))
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return nil, err
+	}
+
+	freeMems := make([]uint64, ret)
+	for i := range freeMems {
+		freeMems[i] = uint64(cFreeMems[i])
+	}
+
+	conn.log.Printf("node cells count: %v\n", ret)
+
+	return freeMems, nil
+}
+
+// NodeFreePages queries the host system on free pages of the specified sizes
+// (in kiB). For "cellCount" NUMA cells starting from "start", the number of
+// free pages of each size in "pages" is returned. The result is indexed by
+// cell first, then by page size; i.e. the number of free pages of size
+// "pages[j]" on cell "start + i" is at position "i*len(pages) + j".
+func (conn Connection) NodeFreePages(pages []uint32, start int32, cellCount uint32) ([]uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	if len(pages) == 0 || cellCount == 0 {
+		return []uint64{}, nil
+	}
+
+	cPages := make([]C.uint, len(pages))
+	for i, p := range pages {
+		cPages[i] = C.uint(p)
+	}
+
+	cCounts := make([]C.ulonglong, len(pages)*int(cellCount))
+
+	conn.log.Printf("reading node free pages %v (start = %v, cells = %v)...\n", pages, start, cellCount)
+	cRet := C.virNodeGetFreePages(conn.virConnect, C.uint(len(pages)), &cPages[0], C.int(start), C.uint(cellCount), &cCounts[0], 0)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return nil, err
+	}
+
+	counts := make([]uint64, ret)
+	for i := range counts {
+		counts[i] = uint64(cCounts[i])
+	}
+
+	conn.log.Printf("node free pages entries count: %v\n", ret)
+
+	return counts, nil
+}
+
+// NodeCPUMap gets the CPU map of the node. The returned slice contains one
+// element per CPU present on the host, which is true if that CPU is online.
+func (conn Connection) NodeCPUMap() ([]bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	var cMap *C.uchar
+	var cOnline C.uint
+
+	conn.log.Println("reading node CPU map...")
+	cRet := C.virNodeGetCPUMap(conn.virConnect, &cMap, &cOnline, 0)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(cMap))
+
+	cpuMap := C.GoBytes(unsafe.Pointer(cMap), C.int((ret+7)/8))
+
+	cpus := make([]bool, ret)
+	for i := range cpus {
+		cpus[i] = (cpuMap[i/8] & (1 << uint(i%8))) != 0
+	}
+
+	conn.log.Printf("node CPUs count: %v (online: %v)\n", ret, uint32(cOnline))
+
+	return cpus, nil
+}
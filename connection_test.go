@@ -2,6 +2,8 @@ package libvirt
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"os"
 	"testing"
 
@@ -28,6 +30,24 @@ func TestConnectionOpenClose(t *testing.T) {
 	}
 }
 
+func TestConnectionOpenContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := OpenContext(ctx, testConnectionURI, ReadWrite, testLogOutput); err != context.Canceled {
+		t.Errorf("unexpected error when opening a connection with a cancelled context; got=%v, want=%v", err, context.Canceled)
+	}
+
+	conn, err := OpenContext(context.Background(), testConnectionURI, ReadWrite, testLogOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = conn.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestConnectionOpenDefault(t *testing.T) {
 	conn, err := OpenDefault()
 	if err != nil {
@@ -233,6 +253,64 @@ func TestConnectionInit(t *testing.T) {
 	}
 }
 
+func TestConnectionCPU(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	cpu, err := env.conn.HostCPU()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cpu.Arch) == 0 {
+		t.Error("host CPU architecture should not be empty")
+	}
+
+	cpuXML, err := cpu.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = env.conn.CompareCPU(utils.RandomString(), CPUCompareDefault); err == nil {
+		t.Error("an error was not returned when comparing an invalid CPU")
+	}
+
+	result, err := env.conn.CompareCPU(cpuXML, CPUCompareFailIncompatible)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if result != CPUCompareIdentical && result != CPUCompareSuperset {
+		t.Errorf("unexpected result when comparing the host CPU with itself; got=%v", result)
+	}
+
+	if _, err = env.conn.BaselineCPU(nil, CPUBaselineDefault); err == nil {
+		t.Error("an error was not returned when computing the baseline of no CPUs")
+	}
+
+	baseline, err := env.conn.BaselineCPU([]string{cpuXML, cpuXML}, CPUBaselineDefault)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(baseline) == 0 {
+		t.Error("baseline CPU should not be empty")
+	}
+
+	if _, err = env.conn.BaselineHypervisorCPU("", "", "", "", nil, CPUBaselineDefault); err == nil {
+		t.Error("an error was not returned when computing the hypervisor baseline of no CPUs")
+	}
+
+	hvBaseline, err := env.conn.BaselineHypervisorCPU("", cpu.Arch, "", "", []string{cpuXML}, CPUBaselineDefault)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(hvBaseline) == 0 {
+		t.Error("hypervisor baseline CPU should not be empty")
+	}
+}
+
 func TestConnectionListDomains(t *testing.T) {
 	env := newTestEnvironment(t).withDomain()
 	defer env.cleanUp()
@@ -595,6 +673,47 @@ func TestConnectionFindStoragePoolSources(t *testing.T) {
 	// TODO: how to test this function on success?
 }
 
+func TestConnectionFindStoragePoolSourceList(t *testing.T) {
+	conn, err := Open("test:///default", ReadWrite, testLogOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the test driver always reports the same LVM volume groups
+	sources, err := conn.FindStoragePoolSourceList("logical", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sources) == 0 {
+		t.Fatal("no storage pool sources were found")
+	}
+
+	src := sources[0]
+	if src.Name == "" || len(src.Devices) == 0 {
+		t.Errorf("unexpected storage pool source; got=%+v", src)
+	}
+
+	name := fmt.Sprintf("pool-%v", utils.RandomString())
+
+	pool, err := conn.DefineStoragePoolFromSource("logical", name, "/dev/"+src.Name, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Free()
+	defer pool.Undefine()
+
+	poolName, err := pool.Name()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if poolName != name {
+		t.Errorf("unexpected storage pool name; got=%v, want=%v", poolName, name)
+	}
+}
+
 func TestConnectionListStoragePools(t *testing.T) {
 	env := newTestEnvironment(t).withStoragePool()
 	defer env.cleanUp()
@@ -868,6 +987,98 @@ func TestConnectionListInterfaces(t *testing.T) {
 	}
 }
 
+func TestConnectionNode(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	info, err := env.conn.NodeInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.Model) == 0 {
+		t.Error("node CPU model should not be empty")
+	}
+
+	if info.CPUs == 0 {
+		t.Error("node CPUs count should be a positive number")
+	}
+
+	if info.Memory == 0 {
+		t.Error("node memory size should be a positive number")
+	}
+
+	if _, err = env.conn.NodeCPUStats(NodeCPUStatsAllCPUs); err != nil {
+		t.Error(err)
+	}
+
+	memStats, err := env.conn.NodeMemoryStats(NodeMemoryStatsAllCells)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if memStats.Total == 0 {
+		t.Error("node total memory should be a positive number")
+	}
+
+	freeMemory, err := env.conn.NodeFreeMemory()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if freeMemory == 0 {
+		t.Error("node free memory should be a positive number")
+	}
+
+	cellsFreeMemory, err := env.conn.NodeCellsFreeMemory(0, int32(info.Nodes))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if l := len(cellsFreeMemory); l == 0 || l > int(info.Nodes) {
+		t.Errorf("unexpected number of NUMA cells; got=%v, want=1..%v", l, info.Nodes)
+	}
+
+	cpus, err := env.conn.NodeCPUMap()
+	if err != nil {
+		t.Error(err)
+	}
+
+	var online uint32
+	for _, cpu := range cpus {
+		if cpu {
+			online++
+		}
+	}
+
+	if online != info.CPUs {
+		t.Errorf("unexpected number of online CPUs; got=%v, want=%v", online, info.CPUs)
+	}
+}
+
+func TestConnectionNodeFreePages(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	counts, err := env.conn.NodeFreePages(nil, 0, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(counts) != 0 {
+		t.Errorf("unexpected free pages without page sizes; got=%v, want=[]", counts)
+	}
+
+	pages := []uint32{4, 2048}
+	if counts, err = env.conn.NodeFreePages(pages, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(counts) != len(pages) {
+		t.Errorf("unexpected number of free pages entries; got=%v, want=%v", len(counts), len(pages))
+	}
+}
+
 func BenchmarkConnectionOpenClose(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		conn, err := Open(testConnectionURI, ReadWrite, testLogOutput)
@@ -0,0 +1,71 @@
+package libvirt
+
+import (
+	"context"
+)
+
+// goContext takes a reference on "obj" and runs "op" in its own goroutine. The
+// reference is only released after "op" finishes, so "obj" remains valid
+// meanwhile. The returned channel receives the result of "op". If "ctx" is
+// already done, nothing is run and no reference is taken.
+func goContext(ctx context.Context, obj Referenced, op func() error) (<-chan error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := obj.Ref(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		defer obj.Free()
+		done <- op()
+	}()
+
+	return done, nil
+}
+
+// runContext runs "op" in the background (see goContext) and waits until it
+// finishes or "ctx" is done, whichever happens first. In the latter case,
+// "abort" (if not nil) is called to cancel the underlying libvirt operation,
+// and ctx.Err() is returned right away, without waiting for "op" to finish.
+func runContext(ctx context.Context, obj Referenced, op func() error, abort func() error) error {
+	done, err := goContext(ctx, obj, op)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if abort != nil {
+			abort()
+		}
+
+		return ctx.Err()
+	}
+}
+
+// runContextWait works like runContext, but after calling "abort" it waits
+// for "op" to return before returning ctx.Err(). It's meant for the
+// operations which use memory owned by the caller (e.g. a stream read), or
+// whose object can't be used until they stop.
+func runContextWait(ctx context.Context, obj Referenced, op func() error, abort func() error) error {
+	done, err := goContext(ctx, obj, op)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		abort()
+		<-done
+
+		return ctx.Err()
+	}
+}
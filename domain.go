@@ -4,9 +4,10 @@ package libvirt
 // #include <libvirt/libvirt.h>
 import "C"
 import (
+	"context"
 	"errors"
-	"log"
 	"reflect"
+	"runtime"
 	"time"
 	"unicode/utf8"
 	"unsafe"
@@ -377,22 +378,70 @@ const (
 	DomSIGRT32   DomainProcessSignal = C.VIR_DOMAIN_PROCESS_SIGNAL_RT32
 )
 
+// DomainStatePollInterval is the interval between two domain state checks
+// while waiting for a state, when lifecycle events aren't available.
+const DomainStatePollInterval = 500 * time.Millisecond
+
+// ErrDomainShutdownTimeout is returned by "ShutdownAndWait" when the domain
+// doesn't shut down in time and it's not allowed to destroy it.
+var ErrDomainShutdownTimeout = errors.New("domain did not shut down in time")
+
+// domainStateEventPollInterval is the interval between two domain state checks
+// while waiting for a state, when lifecycle events are available. It's just a
+// safety net in case events are lost.
+const domainStateEventPollInterval = 5 * time.Second
+
+// DomainBlockStats holds the I/O statistics of a domain block device. Values
+// not supported by the hypervisor are -1.
+type DomainBlockStats struct {
+	ReadRequests  int64
+	ReadBytes     int64
+	WriteRequests int64
+	WriteBytes    int64
+	Errors        int64
+}
+
+// DomainInterfaceStats holds the traffic statistics of a domain network
+// interface. Values not supported by the hypervisor are -1.
+type DomainInterfaceStats struct {
+	RxBytes   int64
+	RxPackets int64
+	RxErrors  int64
+	RxDrop    int64
+	TxBytes   int64
+	TxPackets int64
+	TxErrors  int64
+	TxDrop    int64
+}
+
 // Domain holds a libvirt domain. There are no exported fields.
 type Domain struct {
-	log       *log.Logger
+	log       *logger
+	ref       *objectRef
 	virDomain C.virDomainPtr
 }
 
 // Free frees the domain object. The running instance is kept alive. The data
 // structure is freed and should not be used thereafter.
 func (dom Domain) Free() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	if !dom.ref.release() {
+		dom.log.Println("domain object already freed")
+		return nil
+	}
+
 	dom.log.Println("freeing domain object...")
 	cRet := C.virDomainFree(dom.virDomain)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -404,6 +453,12 @@ func (dom Domain) Free() error {
 // Autostart provides a boolean value indicating whether the domain configured
 // to be automatically started when the host machine boots.
 func (dom Domain) Autostart() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cAutostart C.int
 	dom.log.Println("checking whether domain autostarts...")
 	cRet := C.virDomainGetAutostart(dom.virDomain, &cAutostart)
@@ -411,7 +466,7 @@ func (dom Domain) Autostart() (bool, error) {
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -428,13 +483,19 @@ func (dom Domain) Autostart() (bool, error) {
 
 // HasCurrentSnapshot determines if the domain has a current snapshot.
 func (dom Domain) HasCurrentSnapshot() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("checking whether domain has current snapshot...")
 	cRet := C.virDomainHasCurrentSnapshot(dom.virDomain, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -453,13 +514,19 @@ func (dom Domain) HasCurrentSnapshot() (bool, error) {
 // by ManagedSave(). Note that any running domain should not have such an
 // image, as it should have been removed on restart.
 func (dom Domain) HasManagedSaveImage() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("checking whether domain has managed save...")
 	cRet := C.virDomainHasManagedSaveImage(dom.virDomain, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -476,13 +543,19 @@ func (dom Domain) HasManagedSaveImage() (bool, error) {
 
 // IsActive determines if the domain is currently running.
 func (dom Domain) IsActive() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("checking whether domain is active...")
 	cRet := C.virDomainIsActive(dom.virDomain)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -500,13 +573,19 @@ func (dom Domain) IsActive() (bool, error) {
 // IsPersistent determines if the domain has a persistent configuration which
 // means it will still exist after shutting down
 func (dom Domain) IsPersistent() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("checking whether domain is persistent...")
 	cRet := C.virDomainIsPersistent(dom.virDomain)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -523,13 +602,19 @@ func (dom Domain) IsPersistent() (bool, error) {
 
 // IsUpdated determines if the domain has been updated.
 func (dom Domain) IsUpdated() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("checking whether domain is updated...")
 	cRet := C.virDomainIsUpdated(dom.virDomain)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -546,11 +631,17 @@ func (dom Domain) IsUpdated() (bool, error) {
 
 // OSType gets the type of domain operation system.
 func (dom Domain) OSType() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("reading domain OS type...")
 	cOS := C.virDomainGetOSType(dom.virDomain)
 	if cOS == nil {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cOS))
@@ -563,12 +654,18 @@ func (dom Domain) OSType() (string, error) {
 
 // Name gets the public name for that domain.
 func (dom Domain) Name() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("reading domain name...")
 	cName := C.virDomainGetName(dom.virDomain)
 
 	if cName == nil {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -580,11 +677,17 @@ func (dom Domain) Name() (string, error) {
 
 // Hostname gets the hostname for that domain.
 func (dom Domain) Hostname() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("reading domain hostname...")
 	cHostname := C.virDomainGetHostname(dom.virDomain, 0)
 	if cHostname == nil {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cHostname))
@@ -597,13 +700,19 @@ func (dom Domain) Hostname() (string, error) {
 
 // ID gets the hypervisor ID number for the domain.
 func (dom Domain) ID() (uint32, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("reading domain ID...")
 	cID := C.virDomainGetID(dom.virDomain)
 	id := uint32(cID)
 
 	if id == ^uint32(0) { // Go: ^uint32(0) == C: (unsigned int) -1
 		err := errors.New("domain doesn't have an ID")
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -615,6 +724,12 @@ func (dom Domain) ID() (uint32, error) {
 // UUID gets the UUID for a domain as string. For more information about UUID
 // see RFC4122.
 func (dom Domain) UUID() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cUUID := (*C.char)(C.malloc(C.size_t(C.VIR_UUID_STRING_BUFLEN)))
 	defer C.free(unsafe.Pointer(cUUID))
 
@@ -624,7 +739,7 @@ func (dom Domain) UUID() (string, error) {
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -637,11 +752,17 @@ func (dom Domain) UUID() (string, error) {
 // XML provides an XML description of the domain. The description may be reused
 // later to relaunch the domain with CreateXML().
 func (dom Domain) XML(typ DomainXMLFlag) (string, error) {
-	dom.log.Printf("reading domain XML (flags = %v)...\n", typ)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.with(Field{FieldFlags, typ}).Println("reading domain XML...")
 	cXML := C.virDomainGetXMLDesc(dom.virDomain, C.uint(typ))
 	if cXML == nil {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cXML))
@@ -654,6 +775,12 @@ func (dom Domain) XML(typ DomainXMLFlag) (string, error) {
 
 // Metadata retrieves the appropriate domain element given by "type".
 func (dom Domain) Metadata(typ DomainMetadataType, xmlns string, impact DomainModificationImpact) (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cXMLNS := C.CString(xmlns)
 	defer C.free(unsafe.Pointer(cXMLNS))
 
@@ -661,7 +788,7 @@ func (dom Domain) Metadata(typ DomainMetadataType, xmlns string, impact DomainMo
 	cMetadata := C.virDomainGetMetadata(dom.virDomain, C.int(typ), cXMLNS, C.uint(impact))
 	if cMetadata == nil {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cMetadata))
@@ -677,13 +804,19 @@ func (dom Domain) Metadata(typ DomainMetadataType, xmlns string, impact DomainMo
 // This does not free the associated virDomainPtr object. This function may
 // require privileged access.
 func (dom Domain) Destroy(flags DomainDestroyFlag) error {
-	dom.log.Printf("destroying domain (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.with(Field{FieldFlags, flags}).Println("destroying domain...")
 	cRet := C.virDomainDestroyFlags(dom.virDomain, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -695,13 +828,19 @@ func (dom Domain) Destroy(flags DomainDestroyFlag) error {
 // Create launches a defined domain. If the call succeeds the domain moves from
 // the defined to the running domains pools.
 func (dom Domain) Create(flags DomainCreateFlag) error {
-	dom.log.Printf("starting domain (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.with(Field{FieldFlags, flags}).Println("starting domain...")
 	cRet := C.virDomainCreateWithFlags(dom.virDomain, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -714,13 +853,19 @@ func (dom Domain) Create(flags DomainCreateFlag) error {
 // transient domain, without stopping it. If the domain is inactive, the domain
 // configuration is removed.
 func (dom Domain) Undefine(flags DomainUndefineFlag) error {
-	dom.log.Printf("undefining domain (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.with(Field{FieldFlags, flags}).Println("undefining domain...")
 	cRet := C.virDomainUndefineFlags(dom.virDomain, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -735,13 +880,19 @@ func (dom Domain) Undefine(flags DomainUndefineFlag) error {
 // domain 'on_reboot' XML setting resulting in a domain that shuts down instead
 // of rebooting.
 func (dom Domain) Reboot(flags DomainRebootFlag) error {
-	dom.log.Printf("rebooting domain (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.with(Field{FieldFlags, flags}).Println("rebooting domain...")
 	cRet := C.virDomainReboot(dom.virDomain, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -756,13 +907,19 @@ func (dom Domain) Reboot(flags DomainRebootFlag) error {
 // Note that there is a risk of data loss caused by reset without any guest
 // OS shutdown.
 func (dom Domain) Reset() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("resetting domain...")
 	cRet := C.virDomainReset(dom.virDomain, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -781,13 +938,19 @@ func (dom Domain) Reset() error {
 // as soon as the shutdown request is issued rather than blocking until the
 // guest is no longer running.
 func (dom Domain) Shutdown() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("shutting down domain...")
 	cRet := C.virDomainShutdown(dom.virDomain)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -799,6 +962,12 @@ func (dom Domain) Shutdown() error {
 // State extracts domain state. Each state can be accompanied with a reason
 // (if known) which led to the state.
 func (dom Domain) State() (DomainState, int32, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cState, cReason C.int
 	dom.log.Println("reading domain state...")
 	cRet := C.virDomainGetState(dom.virDomain, &cState, &cReason, 0)
@@ -806,7 +975,7 @@ func (dom Domain) State() (DomainState, int32, error) {
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, 0, err
 	}
 
@@ -823,13 +992,19 @@ func (dom Domain) State() (DomainState, int32, error) {
 // This function may require privileged access. Moreover, suspend may not be
 // supported if domain is in some special state like DomStatePMSuspended.
 func (dom Domain) Suspend() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("suspending domain...")
 	cRet := C.virDomainSuspend(dom.virDomain)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -843,13 +1018,19 @@ func (dom Domain) Suspend() error {
 // privileged access. Moreover, resume may not be supported if domain is in
 // some special state like DomStatePMSuspended.
 func (dom Domain) Resume() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("resuming domain...")
 	cRet := C.virDomainResume(dom.virDomain)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -874,16 +1055,22 @@ func (dom Domain) Resume() error {
 // it cannot do so for the given system; this can allow less pressure on file
 // system cache, but also risks slowing saves to NFS.
 func (dom Domain) CoreDump(file string, format DomainDumpFormat, flags DomainDumpFlag) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cFile := C.CString(file)
 	defer C.free(unsafe.Pointer(cFile))
 
-	dom.log.Printf("dumping domain's core to file %v (format = %v, flags = %v)...", file, format, flags)
+	dom.log.with(Field{FieldFlags, flags}).Printf("dumping domain's core to file %v (format = %v)...", file, format)
 	cRet := C.virDomainCoreDumpWithFormat(dom.virDomain, cFile, C.uint(format), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -892,21 +1079,53 @@ func (dom Domain) CoreDump(file string, format DomainDumpFormat, flags DomainDum
 	return nil
 }
 
+// AbortJob requests that the current background job be aborted at the soonest
+// opportunity. In case the job is a migration in a post-copy mode, this
+// function will report an error.
+func (dom Domain) AbortJob() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.Println("aborting domain job...")
+	cRet := C.virDomainAbortJob(dom.virDomain)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	dom.log.Println("domain job aborted")
+
+	return nil
+}
+
 // Ref increments the reference count on the domain. For each additional call
 // to this method, there shall be a corresponding call to virDomainFree to
 // release the reference count, once the caller no longer needs the reference
 // to this object.
 func (dom Domain) Ref() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("incrementing domain's reference count...")
 	cRet := C.virDomainRef(dom.virDomain)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
+	dom.ref.acquire()
 	dom.log.Println("reference count incremented")
 
 	return nil
@@ -915,13 +1134,19 @@ func (dom Domain) Ref() error {
 // MaxMemory retrieves the maximum amount of physical memory allocated to
 // a domain.
 func (dom Domain) MaxMemory() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("reading domain maximum memory...")
 	cRet := C.virDomainGetMaxMemory(dom.virDomain)
 	ret := uint64(cRet)
 
 	if ret == 0 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -934,13 +1159,19 @@ func (dom Domain) MaxMemory() (uint64, error) {
 // call may fail if the underlying virtualization hypervisor does not support
 // it. This function may require privileged access to the hypervisor.
 func (dom Domain) VCPUs(flags DomainVCPUsFlag) (int32, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("reading domain VCPUs count...")
 	cRet := C.virDomainGetVcpusFlags(dom.virDomain, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -951,12 +1182,20 @@ func (dom Domain) VCPUs(flags DomainVCPUsFlag) (int32, error) {
 
 // InfoState extracts the state of the domain.
 func (dom Domain) InfoState() (DomainState, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virDomainInfo
 	cRet := C.virDomainGetInfo(dom.virDomain, &cInfo)
 	ret := int32(cRet)
 
 	if ret == -1 {
-		return 0, LastError()
+		err := LastError()
+		call.record(err)
+		return 0, err
 	}
 
 	return DomainState(cInfo.state), nil
@@ -964,12 +1203,20 @@ func (dom Domain) InfoState() (DomainState, error) {
 
 // InfoMaxMemory extracts the maximum memory in KBytes allowed in the domain.
 func (dom Domain) InfoMaxMemory() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virDomainInfo
 	cRet := C.virDomainGetInfo(dom.virDomain, &cInfo)
 	ret := int32(cRet)
 
 	if ret == -1 {
-		return 0, LastError()
+		err := LastError()
+		call.record(err)
+		return 0, err
 	}
 
 	return uint64(cInfo.maxMem), nil
@@ -977,12 +1224,20 @@ func (dom Domain) InfoMaxMemory() (uint64, error) {
 
 // InfoMemory extracts the memory in KBytes used by the domain.
 func (dom Domain) InfoMemory() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virDomainInfo
 	cRet := C.virDomainGetInfo(dom.virDomain, &cInfo)
 	ret := int32(cRet)
 
 	if ret == -1 {
-		return 0, LastError()
+		err := LastError()
+		call.record(err)
+		return 0, err
 	}
 
 	return uint64(cInfo.memory), nil
@@ -990,12 +1245,20 @@ func (dom Domain) InfoMemory() (uint64, error) {
 
 // InfoVCPUs extracts the number of virtual CPUs for the domain.
 func (dom Domain) InfoVCPUs() (uint16, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virDomainInfo
 	cRet := C.virDomainGetInfo(dom.virDomain, &cInfo)
 	ret := int32(cRet)
 
 	if ret == -1 {
-		return 0, LastError()
+		err := LastError()
+		call.record(err)
+		return 0, err
 	}
 
 	return uint16(cInfo.nrVirtCpu), nil
@@ -1003,22 +1266,115 @@ func (dom Domain) InfoVCPUs() (uint16, error) {
 
 // InfoCPUTime extracts the CPU time used in nanoseconds.
 func (dom Domain) InfoCPUTime() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virDomainInfo
 	cRet := C.virDomainGetInfo(dom.virDomain, &cInfo)
 	ret := int32(cRet)
 
 	if ret == -1 {
-		return 0, LastError()
+		err := LastError()
+		call.record(err)
+		return 0, err
 	}
 
 	return uint64(cInfo.cpuTime), nil
 }
 
+// BlockStats extracts the I/O statistics of the domain block device "disk",
+// which is either the device target (e.g. "vda") or the path of its source
+// file. The domain must be active.
+func (dom Domain) BlockStats(disk string) (DomainBlockStats, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	cDisk := C.CString(disk)
+	defer C.free(unsafe.Pointer(cDisk))
+
+	var cStats C.virDomainBlockStatsStruct
+
+	dom.log.Printf("reading block device %v statistics...\n", disk)
+	cRet := C.virDomainBlockStats(dom.virDomain, cDisk, &cStats, C.size_t(unsafe.Sizeof(cStats)))
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return DomainBlockStats{}, err
+	}
+
+	stats := DomainBlockStats{
+		ReadRequests:  int64(cStats.rd_req),
+		ReadBytes:     int64(cStats.rd_bytes),
+		WriteRequests: int64(cStats.wr_req),
+		WriteBytes:    int64(cStats.wr_bytes),
+		Errors:        int64(cStats.errs),
+	}
+
+	dom.log.Printf("block device statistics: %+v\n", stats)
+
+	return stats, nil
+}
+
+// InterfaceStats extracts the traffic statistics of the domain network
+// interface "device", which is either the device target (e.g. "vnet0") or its
+// MAC address. The domain must be active.
+func (dom Domain) InterfaceStats(device string) (DomainInterfaceStats, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	cDevice := C.CString(device)
+	defer C.free(unsafe.Pointer(cDevice))
+
+	var cStats C.virDomainInterfaceStatsStruct
+
+	dom.log.Printf("reading network interface %v statistics...\n", device)
+	cRet := C.virDomainInterfaceStats(dom.virDomain, cDevice, &cStats, C.size_t(unsafe.Sizeof(cStats)))
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return DomainInterfaceStats{}, err
+	}
+
+	stats := DomainInterfaceStats{
+		RxBytes:   int64(cStats.rx_bytes),
+		RxPackets: int64(cStats.rx_packets),
+		RxErrors:  int64(cStats.rx_errs),
+		RxDrop:    int64(cStats.rx_drop),
+		TxBytes:   int64(cStats.tx_bytes),
+		TxPackets: int64(cStats.tx_packets),
+		TxErrors:  int64(cStats.tx_errs),
+		TxDrop:    int64(cStats.tx_drop),
+	}
+
+	dom.log.Printf("network interface statistics: %+v\n", stats)
+
+	return stats, nil
+}
+
 // Save suspends a domain and save its memory contents to a file on disk. After
 // the call, if successful, the domain is not listed as running anymore (this
 // ends the life of a transient domain). Use Restore() to restore a domain
 // after saving.
 func (dom Domain) Save(to string, xml string, flags DomainSaveFlag) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cTo := C.CString(to)
 	defer C.free(unsafe.Pointer(cTo))
 
@@ -1030,13 +1386,13 @@ func (dom Domain) Save(to string, xml string, flags DomainSaveFlag) error {
 		cXML = nil
 	}
 
-	dom.log.Printf("saving domain's memory to file %v (flags = %v)...\n", to, flags)
+	dom.log.with(Field{FieldFlags, flags}).Printf("saving domain's memory to file %v...\n", to)
 	cRet := C.virDomainSaveFlags(dom.virDomain, cTo, cXML, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1056,16 +1412,22 @@ func (dom Domain) Save(to string, xml string, flags DomainSaveFlag) error {
 // hypervisor driver will return failure if DomDeviceModifyLive is specified
 // but it only supports modifying the persisted device allocation.
 func (dom Domain) AttachDevice(deviceXML string, flags DomainDeviceModifyFlag) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(deviceXML)
 	defer C.free(unsafe.Pointer(cXML))
 
-	dom.log.Printf("attaching a virtual device to domain (flags = %v)...\n", flags)
+	dom.log.with(Field{FieldFlags, flags}).Println("attaching a virtual device to domain...")
 	cRet := C.virDomainAttachDeviceFlags(dom.virDomain, cXML, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1085,16 +1447,22 @@ func (dom Domain) AttachDevice(deviceXML string, flags DomainDeviceModifyFlag) e
 // hypervisor driver will return failure if DomDeviceModifyLive is specified
 // but it only supports removing the persisted device allocation.
 func (dom Domain) DetachDevice(deviceXML string, flags DomainDeviceModifyFlag) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(deviceXML)
 	defer C.free(unsafe.Pointer(cXML))
 
-	dom.log.Printf("detaching a virtual device from domain (flags = %v)...\n", flags)
+	dom.log.with(Field{FieldFlags, flags}).Println("detaching a virtual device from domain...")
 	cRet := C.virDomainDetachDeviceFlags(dom.virDomain, cXML, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1114,16 +1482,22 @@ func (dom Domain) DetachDevice(deviceXML string, flags DomainDeviceModifyFlag) e
 // return failure if DomDeviceModifyLive is specified but it only supports
 // modifying the persisted device allocation.
 func (dom Domain) UpdateDevice(deviceXML string, flags DomainDeviceModifyFlag) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(deviceXML)
 	defer C.free(unsafe.Pointer(cXML))
 
-	dom.log.Printf("updating a virtual device on domain (flags = %v)...\n", flags)
+	dom.log.with(Field{FieldFlags, flags}).Println("updating a virtual device on domain...")
 	cRet := C.virDomainUpdateDeviceFlags(dom.virDomain, cXML, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1135,6 +1509,12 @@ func (dom Domain) UpdateDevice(deviceXML string, flags DomainDeviceModifyFlag) e
 // SetAutostart configures the domain to be automatically started when the host
 // machine boots.
 func (dom Domain) SetAutostart(autostart bool) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cAutostart C.int
 	if autostart {
 		dom.log.Println("enabling domain autostart...")
@@ -1149,7 +1529,7 @@ func (dom Domain) SetAutostart(autostart bool) error {
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1165,13 +1545,19 @@ func (dom Domain) SetAutostart(autostart bool) error {
 // SetMemory dynamically changes the target amount of physical memory allocated
 // to a domain. This function may require privileged access to the hypervisor.
 func (dom Domain) SetMemory(memory uint64, flags DomainMemoryModifyFlag) error {
-	dom.log.Printf("changing domain memory to %v kiB (flags = %v)...\n", memory, flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.with(Field{FieldFlags, flags}).Printf("changing domain memory to %v kiB...\n", memory)
 	cRet := C.virDomainSetMemoryFlags(dom.virDomain, C.ulong(memory), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1186,6 +1572,12 @@ func (dom Domain) SetMemory(memory uint64, flags DomainMemoryModifyFlag) error {
 // short (although the length is not enforced). For these two options "key" and
 // "uri" are irrelevant and must be set to NULL.
 func (dom Domain) SetMetadata(typ DomainMetadataType, metadata string, key string, uri string, impact DomainModificationImpact) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cMetadata := C.CString(metadata)
 	defer C.free(unsafe.Pointer(cMetadata))
 
@@ -1201,7 +1593,7 @@ func (dom Domain) SetMetadata(typ DomainMetadataType, metadata string, key strin
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1215,13 +1607,19 @@ func (dom Domain) SetMetadata(typ DomainMetadataType, metadata string, key strin
 // does not support it or if growing the number is arbitrary limited. This
 // function may require privileged access to the hypervisor.
 func (dom Domain) SetVCPUs(vcpus uint32, flags DomainVCPUsFlag) error {
-	dom.log.Printf("changing domain VCPUs count to %v (flags = %v)...\n", vcpus, flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.with(Field{FieldFlags, flags}).Printf("changing domain VCPUs count to %v...\n", vcpus)
 	cRet := C.virDomainSetVcpusFlags(dom.virDomain, C.uint(vcpus), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1239,13 +1637,19 @@ func (dom Domain) SetVCPUs(vcpus uint32, flags DomainVCPUsFlag) error {
 // managed save only works on persistent domains, since the domain must still
 // exist in order to use Create() to restart it.
 func (dom Domain) ManagedSave(flags DomainSaveFlag) error {
-	dom.log.Printf("saving domain's memory to a libvirt-managed location (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
+	dom.log.with(Field{FieldFlags, flags}).Println("saving domain's memory to a libvirt-managed location...")
 	cRet := C.virDomainManagedSave(dom.virDomain, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1256,13 +1660,19 @@ func (dom Domain) ManagedSave(flags DomainSaveFlag) error {
 
 // ManagedSaveRemove removes any managed save image for this domain.
 func (dom Domain) ManagedSaveRemove() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Println("removing libvirt-managed domain save image...")
 	cRet := C.virDomainManagedSaveRemove(dom.virDomain, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1273,13 +1683,19 @@ func (dom Domain) ManagedSaveRemove() error {
 
 // SendKey send key(s) to the guest.
 func (dom Domain) SendKey(codeSet DomainKeycodeSet, hold time.Duration, keycodes []uint32) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Printf("sending keys %v (keycode set = %v) to domain during %v...\n", keycodes, codeSet, time.Duration(hold))
 	cRet := C.virDomainSendKey(dom.virDomain, C.uint(codeSet), C.uint(hold*time.Millisecond), (*C.uint)(unsafe.Pointer(&keycodes[0])), C.int(len(keycodes)), 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1290,13 +1706,19 @@ func (dom Domain) SendKey(codeSet DomainKeycodeSet, hold time.Duration, keycodes
 
 // SendProcessSignal sends a signal to the designated process in the guest.
 func (dom Domain) SendProcessSignal(pid int64, signal DomainProcessSignal) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	dom.log.Printf("sending signal %v to domain's process %v...\n", signal, pid)
 	cRet := C.virDomainSendProcessSignal(dom.virDomain, C.longlong(pid), C.uint(signal), 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -1308,16 +1730,22 @@ func (dom Domain) SendProcessSignal(pid int64, signal DomainProcessSignal) error
 // ListSnapshots collects the list of domain snapshots for the given domain, and
 // allocate an array to store those objects.
 func (dom Domain) ListSnapshots(flags SnapshotListFlag) ([]Snapshot, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	var cSnaps []C.virDomainSnapshotPtr
 	snapsSH := (*reflect.SliceHeader)(unsafe.Pointer(&cSnaps))
 
-	dom.log.Printf("reading domain snapshots (flags = %v)...\n", flags)
+	dom.log.with(Field{FieldFlags, flags}).Println("reading domain snapshots...")
 	cRet := C.virDomainListAllSnapshots(dom.virDomain, (**C.virDomainSnapshotPtr)(unsafe.Pointer(&snapsSH.Data)), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return nil, err
 	}
 	defer C.free(unsafe.Pointer(snapsSH.Data))
@@ -1329,8 +1757,9 @@ func (dom Domain) ListSnapshots(flags SnapshotListFlag) ([]Snapshot, error) {
 
 	for i := range snaps {
 		snaps[i] = Snapshot{
-			log:         dom.log,
+			log:         dom.log.with(Field{FieldObject, objectSnapshot}),
 			virSnapshot: cSnaps[i],
+			ref:         newSnapshotRef(cSnaps[i]),
 		}
 	}
 
@@ -1341,20 +1770,27 @@ func (dom Domain) ListSnapshots(flags SnapshotListFlag) ([]Snapshot, error) {
 
 // CreateSnapshot creates a new snapshot of a domain based on a snapshot XML.
 func (dom Domain) CreateSnapshot(xml string, flags SnapshotCreateFlag) (Snapshot, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(xml)
 	defer C.free(unsafe.Pointer(cXML))
 
-	dom.log.Printf("creating domain snapshot (flags = %v)...\n", flags)
+	dom.log.with(Field{FieldFlags, flags}).Println("creating domain snapshot...")
 	cSnapshot := C.virDomainSnapshotCreateXML(dom.virDomain, cXML, C.uint(flags))
 	if cSnapshot == nil {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Snapshot{}, err
 	}
 
 	snap := Snapshot{
-		log:         dom.log,
+		log:         dom.log.with(Field{FieldObject, objectSnapshot}),
 		virSnapshot: cSnapshot,
+		ref:         newSnapshotRef(cSnapshot),
 	}
 
 	dom.log.Println("snapshot created")
@@ -1364,6 +1800,12 @@ func (dom Domain) CreateSnapshot(xml string, flags SnapshotCreateFlag) (Snapshot
 
 // LookupSnapshotByName tries to lookup a domain snapshot based on its name.
 func (dom Domain) LookupSnapshotByName(name string) (Snapshot, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(dom.ref)
+	call := dom.log.startCall()
+	defer call.finish()
+
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
 
@@ -1371,16 +1813,184 @@ func (dom Domain) LookupSnapshotByName(name string) (Snapshot, error) {
 	cSnap := C.virDomainSnapshotLookupByName(dom.virDomain, cName, 0)
 	if cSnap == nil {
 		err := LastError()
-		dom.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Snapshot{}, err
 	}
 
 	snap := Snapshot{
-		log:         dom.log,
+		log:         dom.log.with(Field{FieldObject, objectSnapshot}, Field{FieldName, name}),
 		virSnapshot: cSnap,
+		ref:         newSnapshotRef(cSnap),
 	}
 
 	dom.log.Println("snapshot found")
 
 	return snap, nil
 }
+
+// CreateContext works like Create, but returns ctx.Err() as soon as "ctx" is
+// done, even if the domain hasn't been started yet.
+func (dom Domain) CreateContext(ctx context.Context, flags DomainCreateFlag) error {
+	return runContext(ctx, dom, func() error {
+		return dom.Create(flags)
+	}, nil)
+}
+
+// DestroyContext works like Destroy, but returns ctx.Err() as soon as "ctx" is
+// done, even if the domain hasn't been destroyed yet.
+func (dom Domain) DestroyContext(ctx context.Context, flags DomainDestroyFlag) error {
+	return runContext(ctx, dom, func() error {
+		return dom.Destroy(flags)
+	}, nil)
+}
+
+// RebootContext works like Reboot, but returns ctx.Err() as soon as "ctx" is
+// done, even if the reboot request hasn't been issued yet.
+func (dom Domain) RebootContext(ctx context.Context, flags DomainRebootFlag) error {
+	return runContext(ctx, dom, func() error {
+		return dom.Reboot(flags)
+	}, nil)
+}
+
+// ShutdownContext works like Shutdown, but returns ctx.Err() as soon as "ctx"
+// is done, even if the shutdown request hasn't been issued yet.
+func (dom Domain) ShutdownContext(ctx context.Context) error {
+	return runContext(ctx, dom, func() error {
+		return dom.Shutdown()
+	}, nil)
+}
+
+// SaveContext works like Save, but aborts the save job (see AbortJob) and
+// returns ctx.Err() as soon as "ctx" is done.
+func (dom Domain) SaveContext(ctx context.Context, to string, xml string, flags DomainSaveFlag) error {
+	return runContext(ctx, dom, func() error {
+		return dom.Save(to, xml, flags)
+	}, dom.AbortJob)
+}
+
+// ManagedSaveContext works like ManagedSave, but aborts the save job (see
+// AbortJob) and returns ctx.Err() as soon as "ctx" is done.
+func (dom Domain) ManagedSaveContext(ctx context.Context, flags DomainSaveFlag) error {
+	return runContext(ctx, dom, func() error {
+		return dom.ManagedSave(flags)
+	}, dom.AbortJob)
+}
+
+// CoreDumpContext works like CoreDump, but aborts the dump job (see AbortJob)
+// and returns ctx.Err() as soon as "ctx" is done.
+func (dom Domain) CoreDumpContext(ctx context.Context, file string, format DomainDumpFormat, flags DomainDumpFlag) error {
+	return runContext(ctx, dom, func() error {
+		return dom.CoreDump(file, format, flags)
+	}, dom.AbortJob)
+}
+
+// connection returns the connection which owns the domain. It doesn't hold
+// a new reference, so it must not be closed nor used after the domain is freed.
+func (dom Domain) connection() Connection {
+	return Connection{
+		log:        dom.log.with(Field{FieldObject, objectConnection}),
+		virConnect: C.virDomainGetConnect(dom.virDomain),
+	}
+}
+
+// WaitForState blocks until the domain reaches one of the given states, and
+// returns that state. It returns ctx.Err() (and the latest state) if "ctx" is
+// done before that.
+// If the event loop is running (see StartEventLoop), the state is checked
+// again whenever a lifecycle event is received for the domain; otherwise, it's
+// checked every DomainStatePollInterval.
+func (dom Domain) WaitForState(ctx context.Context, states ...DomainState) (DomainState, error) {
+	var events <-chan DomainLifecycleEvent
+	interval := DomainStatePollInterval
+
+	if EventLoopRunning() {
+		ch, sub, err := dom.connection().SubscribeDomainLifecycleEvents(&dom)
+		if err == nil {
+			defer sub.Close()
+			events = ch
+			interval = domainStateEventPollInterval
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	dom.log.Printf("waiting for domain states %v...\n", states)
+
+	for {
+		state, _, err := dom.State()
+		if err != nil {
+			return 0, err
+		}
+
+		for _, s := range states {
+			if state == s {
+				dom.log.Printf("domain reached state %v\n", state)
+				return state, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return state, ctx.Err()
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// ShutdownAndWait shuts down the domain and waits until it's shut off. If the
+// domain doesn't shut down within "timeout", it's destroyed when "forceAfter"
+// is true: libvirt first tries to terminate it gracefully (DomDestroyGraceful)
+// and, if that fails, it's destroyed forcefully (DomDestroyDefault). Otherwise
+// ErrDomainShutdownTimeout is returned. The whole operation is cancelled when
+// "ctx" is done, and ctx.Err() is returned.
+func (dom Domain) ShutdownAndWait(ctx context.Context, timeout time.Duration, forceAfter bool) error {
+	if err := dom.ShutdownContext(ctx); err != nil {
+		if state, _, stateErr := dom.State(); stateErr == nil && state == DomStateShutoff {
+			return nil
+		}
+
+		return err
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, timeout)
+	_, err := dom.WaitForState(shutdownCtx, DomStateShutoff)
+	cancel()
+
+	if err == nil {
+		return nil
+	}
+
+	if err != context.DeadlineExceeded || ctx.Err() != nil {
+		return err
+	}
+
+	if !forceAfter {
+		return ErrDomainShutdownTimeout
+	}
+
+	dom.log.Println("domain did not shut down in time; destroying it...")
+
+	if err = dom.DestroyContext(ctx, DomDestroyGraceful); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if err = dom.DestroyContext(ctx, DomDestroyDefault); err != nil {
+			// the domain may have shut down by itself in the meantime
+			if state, _, stateErr := dom.State(); stateErr == nil && state == DomStateShutoff {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	_, err = dom.WaitForState(ctx, DomStateShutoff)
+
+	return err
+}
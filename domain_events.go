@@ -0,0 +1,115 @@
+package libvirt
+
+/*
+#include <stdlib.h>
+#include <libvirt/libvirt.h>
+
+int virConnectDomainEventRegisterLifecycleWrapper(virConnectPtr conn, virDomainPtr dom, long callbackID);
+*/
+import "C"
+import (
+	"runtime"
+	"unsafe"
+)
+
+// DomainEventType describes a domain lifecycle event.
+type DomainEventType int32
+
+// Possible values for DomainEventType.
+const (
+	DomEventDefined     DomainEventType = C.VIR_DOMAIN_EVENT_DEFINED
+	DomEventUndefined   DomainEventType = C.VIR_DOMAIN_EVENT_UNDEFINED
+	DomEventStarted     DomainEventType = C.VIR_DOMAIN_EVENT_STARTED
+	DomEventSuspended   DomainEventType = C.VIR_DOMAIN_EVENT_SUSPENDED
+	DomEventResumed     DomainEventType = C.VIR_DOMAIN_EVENT_RESUMED
+	DomEventStopped     DomainEventType = C.VIR_DOMAIN_EVENT_STOPPED
+	DomEventShutdown    DomainEventType = C.VIR_DOMAIN_EVENT_SHUTDOWN
+	DomEventPMSuspended DomainEventType = C.VIR_DOMAIN_EVENT_PMSUSPENDED
+	DomEventCrashed     DomainEventType = C.VIR_DOMAIN_EVENT_CRASHED
+)
+
+// DomainLifecycleEvent is delivered when the lifecycle of a domain changes.
+// The meaning of "Detail" depends on "Event"; e.g. for DomEventStopped it
+// tells why the domain has been stopped.
+type DomainLifecycleEvent struct {
+	DomainName string
+	DomainUUID string
+	Event      DomainEventType
+	Detail     int32
+}
+
+// domainLifecycleHandler delivers domain lifecycle events to a channel.
+type domainLifecycleHandler struct {
+	eventHandler
+	events chan DomainLifecycleEvent
+}
+
+// SubscribeDomainLifecycleEvents starts delivering the lifecycle events of the
+// domain "dom" to the returned channel. If "dom" is nil, the events of every
+// domain are delivered. Events are dropped if the channel is full, so it
+// should be drained constantly. The channel is closed after the subscription
+// is closed.
+// Events are only delivered while the event loop is running (see
+// StartEventLoop), which must happen before the connection is opened.
+func (conn Connection) SubscribeDomainLifecycleEvents(dom *Domain) (<-chan DomainLifecycleEvent, *EventSubscription, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	var cDomain C.virDomainPtr
+	if dom != nil {
+		cDomain = dom.virDomain
+	}
+
+	handler := &domainLifecycleHandler{
+		events: make(chan DomainLifecycleEvent, eventChannelSize),
+	}
+	handler.done = func() {
+		close(handler.events)
+	}
+
+	sub, err := conn.subscribeEvents("domain lifecycle", handler,
+		func(libvirtCallbackID C.int) C.int {
+			return C.virConnectDomainEventDeregisterAny(conn.virConnect, libvirtCallbackID)
+		},
+		func(callbackID C.long) C.int {
+			return C.virConnectDomainEventRegisterLifecycleWrapper(conn.virConnect, cDomain, callbackID)
+		},
+	)
+	if err != nil {
+		call.error(err)
+		return nil, nil, err
+	}
+
+	return handler.events, sub, nil
+}
+
+//export domainEventLifecycleCallback
+func domainEventLifecycleCallback(cConn C.virConnectPtr, cDomain C.virDomainPtr, cEvent C.int, cDetail C.int, cCallbackID C.long) {
+	handler, ok := lookupCallback(int(cCallbackID)).(*domainLifecycleHandler)
+	if !ok {
+		return
+	}
+
+	event := DomainLifecycleEvent{
+		Event:  DomainEventType(cEvent),
+		Detail: int32(cDetail),
+	}
+
+	if cName := C.virDomainGetName(cDomain); cName != nil {
+		event.DomainName = C.GoString(cName)
+	}
+
+	cUUID := (*C.char)(C.malloc(C.size_t(C.VIR_UUID_STRING_BUFLEN)))
+	defer C.free(unsafe.Pointer(cUUID))
+
+	if C.virDomainGetUUIDString(cDomain, cUUID) == 0 {
+		event.DomainUUID = C.GoString(cUUID)
+	}
+
+	select {
+	case handler.events <- event:
+	default:
+	}
+}
@@ -0,0 +1,17 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+
+void domainEventLifecycleCallback(virConnectPtr conn, virDomainPtr dom, int event, int detail, long callbackID);
+void freeCallbackHelper(void *opaque);
+
+static void domainEventLifecycleCallbackHelper(virConnectPtr conn, virDomainPtr dom, int event, int detail, void *opaque) {
+    domainEventLifecycleCallback(conn, dom, event, detail, (long)opaque);
+}
+
+int virConnectDomainEventRegisterLifecycleWrapper(virConnectPtr conn, virDomainPtr dom, long callbackID) {
+    return virConnectDomainEventRegisterAny(conn, dom, VIR_DOMAIN_EVENT_ID_LIFECYCLE, VIR_DOMAIN_EVENT_CALLBACK(domainEventLifecycleCallbackHelper), (void *)callbackID, freeCallbackHelper);
+}
+*/
+import "C"
@@ -2,6 +2,7 @@ package libvirt
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -246,6 +247,90 @@ func TestDomainShutdown(t *testing.T) {
 	}
 }
 
+func TestDomainContext(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := env.dom.CreateContext(ctx, DomCreateAutodestroy); err != context.Canceled {
+		t.Errorf("unexpected error when starting a domain with a cancelled context; got=%v, want=%v", err, context.Canceled)
+	}
+
+	if refs := env.dom.ref.refs; refs != 1 {
+		t.Errorf("unexpected domain reference count after a cancelled call; got=%v, want=%v", refs, 1)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := env.dom.CreateContext(ctx, DomCreateAutodestroy); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.dom.ShutdownContext(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if err := env.dom.DestroyContext(ctx, DomDestroyDefault); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDomainWaitForState(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	state, err := env.dom.WaitForState(ctx, DomStateShutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state != DomStateShutoff {
+		t.Errorf("unexpected domain state; got=%v, want=%v", state, DomStateShutoff)
+	}
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, 2*DomainStatePollInterval)
+	defer shortCancel()
+
+	if _, err = env.dom.WaitForState(shortCtx, DomStateRunning); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error when waiting for an unreachable state; got=%v, want=%v", err, context.DeadlineExceeded)
+	}
+
+	if err = env.dom.Create(DomCreateAutodestroy); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, err = env.dom.WaitForState(ctx, DomStateRunning, DomStatePaused); err != nil {
+		t.Fatal(err)
+	}
+
+	if state != DomStateRunning {
+		t.Errorf("unexpected domain state; got=%v, want=%v", state, DomStateRunning)
+	}
+
+	if err = env.dom.ShutdownAndWait(ctx, 10*time.Second, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, _, err = env.dom.State(); err != nil {
+		t.Fatal(err)
+	}
+
+	if state != DomStateShutoff {
+		t.Errorf("unexpected domain state after shutdown; got=%v, want=%v", state, DomStateShutoff)
+	}
+
+	// shutting down a domain which is already off is not an error
+	if err = env.dom.ShutdownAndWait(ctx, time.Second, false); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestDomainSuspendResume(t *testing.T) {
 	env := newTestEnvironment(t).withDomain()
 	defer env.cleanUp()
@@ -510,6 +595,32 @@ func TestDomainInfo(t *testing.T) {
 	}
 }
 
+func TestDomainStats(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	if _, err := env.dom.BlockStats(env.domData.DiskTarget); err == nil {
+		t.Error("an error was not returned when reading block statistics of an inactive domain")
+	}
+
+	if err := env.dom.Create(DomCreateAutodestroy); err != nil {
+		t.Fatal(err)
+	}
+	defer env.dom.Destroy(DomDestroyDefault)
+
+	if _, err := env.dom.BlockStats(env.domData.DiskTarget); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := env.dom.BlockStats(utils.RandomString()); err == nil {
+		t.Error("an error was not returned when reading statistics of a non-existing block device")
+	}
+
+	if _, err := env.dom.InterfaceStats(utils.RandomString()); err == nil {
+		t.Error("an error was not returned when reading statistics of a non-existing network interface")
+	}
+}
+
 func TestDomainSaveRestore(t *testing.T) {
 	env := newTestEnvironment(t).withDomain()
 	defer env.cleanUp()
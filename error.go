@@ -3,8 +3,12 @@ package libvirt
 // #include <libvirt/virterror.h>
 import "C"
 import (
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
+	"runtime"
+	"strings"
 )
 
 // ErrorCode is the error code.
@@ -184,7 +188,9 @@ const (
 	ErrLvlError   ErrorLevel = C.VIR_ERR_ERROR
 )
 
-// Error is a wrapper for a native libvirt error.
+// Error is a wrapper for a native libvirt error. "Op" is the name of the
+// method which failed (e.g. "Connection.LookupDomainByName"), when the error
+// was returned by this package.
 type Error struct {
 	Code             ErrorCode
 	Domain           ErrorDomain
@@ -192,12 +198,37 @@ type Error struct {
 	Level            ErrorLevel
 	Str1, Str2, Str3 string
 	Int1, Int2       int32
+	Op               string
 }
 
 func (err *Error) Error() string {
+	if err.Op != "" {
+		return fmt.Sprintf("%s: %s [error code = %d]", err.Op, err.Message, err.Code)
+	}
+
 	return fmt.Sprintf("%s [error code = %d]", err.Message, err.Code)
 }
 
+// Is reports whether the error matches "target". An error matches an
+// ErrorCode when it has that code, so errors.Is(err, ErrNoDomain) can be used
+// to check for a specific libvirt error.
+func (err *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrorCode:
+		return err.Code == t
+	case *Error:
+		return err.Code == t.Code && err.Domain == t.Domain
+	default:
+		return false
+	}
+}
+
+// Error makes ErrorCode satisfy the "error" interface, so the codes can be
+// used as targets for errors.Is.
+func (code ErrorCode) Error() string {
+	return code.String()
+}
+
 // NewError creates an error based on a native libvirt error. If the libvirt
 // error pointer is nil, returns nil.
 func NewError(virError C.virErrorPtr) *Error {
@@ -206,26 +237,145 @@ func NewError(virError C.virErrorPtr) *Error {
 	}
 
 	return &Error{
-		ErrorCode(virError.code),
-		ErrorDomain(virError.domain),
-		C.GoString(virError.message),
-		ErrorLevel(virError.level),
-		C.GoString(virError.str1),
-		C.GoString(virError.str2),
-		C.GoString(virError.str2),
-		int32(virError.int1),
-		int32(virError.int2),
+		Code:    ErrorCode(virError.code),
+		Domain:  ErrorDomain(virError.domain),
+		Message: C.GoString(virError.message),
+		Level:   ErrorLevel(virError.level),
+		Str1:    C.GoString(virError.str1),
+		Str2:    C.GoString(virError.str2),
+		Str3:    C.GoString(virError.str3),
+		Int1:    int32(virError.int1),
+		Int2:    int32(virError.int2),
+	}
+}
+
+// errorCode returns the libvirt error code of "err", or ErrOK if it's not a
+// libvirt error.
+func errorCode(err error) ErrorCode {
+	var virErr *Error
+	if errors.As(err, &virErr) {
+		return virErr.Code
+	}
+
+	return ErrOK
+}
+
+// IsNotFound reports whether "err" is a libvirt error caused by a missing
+// object (domain, network, storage pool, etc).
+func IsNotFound(err error) bool {
+	switch errorCode(err) {
+	case ErrNoDomain, ErrNoNetwork, ErrNoStoragePool, ErrNoStorageVol,
+		ErrNoNodeDevice, ErrNoInterface, ErrNoNwFilter, ErrNoSecret,
+		ErrNoDomainSnapshot, ErrNoDomainMetadata:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsAlreadyExists reports whether "err" is a libvirt error caused by an
+// object which already exists.
+func IsAlreadyExists(err error) bool {
+	switch errorCode(err) {
+	case ErrDomExist, ErrNetworkExist, ErrStorageVolExist:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsOperationInvalid reports whether "err" is a libvirt error caused by an
+// operation which is not valid in the current object state (e.g. starting a
+// domain which is already running).
+func IsOperationInvalid(err error) bool {
+	return errorCode(err) == ErrOperationInvalid
+}
+
+// IsRetryable reports whether "err" is a transient libvirt error, i.e. the
+// same operation may succeed if it's attempted again later.
+func IsRetryable(err error) bool {
+	switch errorCode(err) {
+	case ErrOperationTimeout, ErrAgentUnresponsive, ErrResourceBusy:
+		return true
+	default:
+		return IsConnectionLost(err)
+	}
+}
+
+// IsConnectionLost reports whether "err" is a libvirt error caused by a
+// broken connection to the hypervisor.
+func IsConnectionLost(err error) bool {
+	var virErr *Error
+	if !errors.As(err, &virErr) {
+		return false
+	}
+
+	switch virErr.Code {
+	case ErrNoConnect, ErrInvalidConn, ErrRPC:
+		return true
+	case ErrSystem, ErrInternal:
+		return virErr.Domain == ErrDomRPC
+	default:
+		return false
 	}
 }
 
 // LastError provides a pointer to the last error caught at the library level.
-// The error object is kept in thread local storage, so separate threads can
-// safely access this concurrently.
+// The error object is kept in thread local storage, so the caller must be
+// running on the same OS thread as the function which failed (see
+// runtime.LockOSThread); otherwise, it may get no error at all or an error
+// from another goroutine. Only the returned copy is released; the thread local
+// error is kept until the next libvirt call resets it.
+// If libvirt has no error to report (e.g. another call has reset it in the
+// meantime), a generic ErrInternal error is returned instead, so a failed call
+// is never reported as a nil error.
 func LastError() *Error {
-	cError := C.virGetLastError()
-	if cError == nil {
+	var cError C.virError
+	if C.virCopyLastError(&cError) <= 0 {
 		log.Println("LastError() did not return an error")
+
+		return &Error{
+			Code:    ErrInternal,
+			Domain:  ErrDomNone,
+			Message: "libvirt did not report the error",
+			Level:   ErrLvlError,
+			Op:      callerName(2),
+		}
 	}
+	defer C.virResetError(&cError)
+
+	err := NewError(&cError)
+	err.Op = callerName(2)
+
+	return err
+}
+
+// packagePath is the import path of this package, used to tell its functions
+// apart from the caller's.
+var packagePath = reflect.TypeOf(Error{}).PkgPath()
+
+// callerName returns the name of the function "skip" frames above the caller
+// (e.g. "Connection.LookupDomainByName"), if it belongs to this package.
+// Otherwise, it returns an empty string.
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+
+	name := fn.Name()
+	if !strings.HasPrefix(name, packagePath+".") {
+		return ""
+	}
+
+	name = strings.TrimPrefix(name, packagePath+".")
+	name = strings.Replace(name, "(", "", -1)
+	name = strings.Replace(name, ")", "", -1)
 
-	return NewError(cError)
+	return strings.TrimPrefix(name, "*")
 }
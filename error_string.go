@@ -0,0 +1,195 @@
+package libvirt
+
+import "strconv"
+
+var errorCodeNames = map[ErrorCode]string{
+	ErrOK:                    "ErrOK",
+	ErrInternal:              "ErrInternal",
+	ErrNoMemory:              "ErrNoMemory",
+	ErrNoSupport:             "ErrNoSupport",
+	ErrUnknownHost:           "ErrUnknownHost",
+	ErrNoConnect:             "ErrNoConnect",
+	ErrInvalidConn:           "ErrInvalidConn",
+	ErrInvalidDomain:         "ErrInvalidDomain",
+	ErrInvalidArg:            "ErrInvalidArg",
+	ErrOperationFailed:       "ErrOperationFailed",
+	ErrGetFailed:             "ErrGetFailed",
+	ErrPostFailed:            "ErrPostFailed",
+	ErrHTTP:                  "ErrHTTP",
+	ErrSExprSerial:           "ErrSExprSerial",
+	ErrNoXen:                 "ErrNoXen",
+	ErrXenCall:               "ErrXenCall",
+	ErrOSType:                "ErrOSType",
+	ErrNoKernel:              "ErrNoKernel",
+	ErrNoRoot:                "ErrNoRoot",
+	ErrNoSource:              "ErrNoSource",
+	ErrNoTarget:              "ErrNoTarget",
+	ErrNoName:                "ErrNoName",
+	ErrNoOS:                  "ErrNoOS",
+	ErrNoDevice:              "ErrNoDevice",
+	ErrNoXenStore:            "ErrNoXenStore",
+	ErrDriverFull:            "ErrDriverFull",
+	ErrCallFailed:            "ErrCallFailed",
+	ErrXML:                   "ErrXML",
+	ErrDomExist:              "ErrDomExist",
+	ErrOperationDenied:       "ErrOperationDenied",
+	ErrOpenFailed:            "ErrOpenFailed",
+	ErrReadFailed:            "ErrReadFailed",
+	ErrParseFailed:           "ErrParseFailed",
+	ErrConfSyntax:            "ErrConfSyntax",
+	ErrWriteFailed:           "ErrWriteFailed",
+	ErrXMLDetail:             "ErrXMLDetail",
+	ErrInvalidNetwork:        "ErrInvalidNetwork",
+	ErrNetworkExist:          "ErrNetworkExist",
+	ErrSystem:                "ErrSystem",
+	ErrRPC:                   "ErrRPC",
+	ErrGNUTLS:                "ErrGNUTLS",
+	WarNoNetwork:             "WarNoNetwork",
+	ErrNoDomain:              "ErrNoDomain",
+	ErrNoNetwork:             "ErrNoNetwork",
+	ErrInvalidMAC:            "ErrInvalidMAC",
+	ErrAuthFailed:            "ErrAuthFailed",
+	ErrInvalidStoragePool:    "ErrInvalidStoragePool",
+	ErrInvalidStorageVol:     "ErrInvalidStorageVol",
+	WarNoStorage:             "WarNoStorage",
+	ErrNoStoragePool:         "ErrNoStoragePool",
+	ErrNoStorageVol:          "ErrNoStorageVol",
+	WarNoNode:                "WarNoNode",
+	ErrInvalidNodeDevice:     "ErrInvalidNodeDevice",
+	ErrNoNodeDevice:          "ErrNoNodeDevice",
+	ErrNoSecurityModel:       "ErrNoSecurityModel",
+	ErrOperationInvalid:      "ErrOperationInvalid",
+	WarNoInterface:           "WarNoInterface",
+	ErrNoInterface:           "ErrNoInterface",
+	ErrInvalidInterface:      "ErrInvalidInterface",
+	ErrMultipleInterfaces:    "ErrMultipleInterfaces",
+	WarNoNwFilter:            "WarNoNwFilter",
+	ErrInvalidNwFilter:       "ErrInvalidNwFilter",
+	ErrNoNwFilter:            "ErrNoNwFilter",
+	ErrBuildFirewall:         "ErrBuildFirewall",
+	WarNoSecret:              "WarNoSecret",
+	ErrInvalidSecret:         "ErrInvalidSecret",
+	ErrNoSecret:              "ErrNoSecret",
+	ErrConfigUnsupported:     "ErrConfigUnsupported",
+	ErrOperationTimeout:      "ErrOperationTimeout",
+	ErrMigratePersistFailed:  "ErrMigratePersistFailed",
+	ErrHookScriptFailed:      "ErrHookScriptFailed",
+	ErrInvalidDomainSnapshot: "ErrInvalidDomainSnapshot",
+	ErrNoDomainSnapshot:      "ErrNoDomainSnapshot",
+	ErrInvalidStream:         "ErrInvalidStream",
+	ErrArgumentUnsupported:   "ErrArgumentUnsupported",
+	ErrStorageProbeFailed:    "ErrStorageProbeFailed",
+	ErrStoragePoolBuilt:      "ErrStoragePoolBuilt",
+	ErrSnapshotRevertRisky:   "ErrSnapshotRevertRisky",
+	ErrOperationAborted:      "ErrOperationAborted",
+	ErrAuthCancelled:         "ErrAuthCancelled",
+	ErrNoDomainMetadata:      "ErrNoDomainMetadata",
+	ErrMigrateUnsafe:         "ErrMigrateUnsafe",
+	ErrOverflow:              "ErrOverflow",
+	ErrBlockCopyActive:       "ErrBlockCopyActive",
+	ErrOperationUnsupported:  "ErrOperationUnsupported",
+	ErrSSH:                   "ErrSSH",
+	ErrAgentUnresponsive:     "ErrAgentUnresponsive",
+	ErrResourceBusy:          "ErrResourceBusy",
+	ErrAccessDenied:          "ErrAccessDenied",
+	ErrDBusService:           "ErrDBusService",
+	ErrStorageVolExist:       "ErrStorageVolExist",
+	ErrCPUIncompatible:       "ErrCPUIncompatible",
+}
+
+// String returns the name of the error code constant.
+func (code ErrorCode) String() string {
+	if name, ok := errorCodeNames[code]; ok {
+		return name
+	}
+
+	return "ErrorCode(" + strconv.FormatUint(uint64(code), 10) + ")"
+}
+
+var errorDomainNames = map[ErrorDomain]string{
+	ErrDomNone:           "ErrDomNone",
+	ErrDomXen:            "ErrDomXen",
+	ErrDomXend:           "ErrDomXend",
+	ErrDomXenStore:       "ErrDomXenStore",
+	ErrDomSExpr:          "ErrDomSExpr",
+	ErrDomXML:            "ErrDomXML",
+	ErrDomDom:            "ErrDomDom",
+	ErrDomRPC:            "ErrDomRPC",
+	ErrDomProxy:          "ErrDomProxy",
+	ErrDomConf:           "ErrDomConf",
+	ErrDomQEMU:           "ErrDomQEMU",
+	ErrDomNet:            "ErrDomNet",
+	ErrDomTest:           "ErrDomTest",
+	ErrDomRemote:         "ErrDomRemote",
+	ErrDomOpenVZ:         "ErrDomOpenVZ",
+	ErrDomXenXM:          "ErrDomXenXM",
+	ErrDomStatsLinux:     "ErrDomStatsLinux",
+	ErrDomLXC:            "ErrDomLXC",
+	ErrDomStorage:        "ErrDomStorage",
+	ErrDomNetwork:        "ErrDomNetwork",
+	ErrDomDomain:         "ErrDomDomain",
+	ErrDomUML:            "ErrDomUML",
+	ErrDomNodeDev:        "ErrDomNodeDev",
+	ErrDomXenInotify:     "ErrDomXenInotify",
+	ErrDomSecurity:       "ErrDomSecurity",
+	ErrDomVBox:           "ErrDomVBox",
+	ErrDomInterface:      "ErrDomInterface",
+	ErrDomONE:            "ErrDomONE",
+	ErrDomESX:            "ErrDomESX",
+	ErrDomPHYP:           "ErrDomPHYP",
+	ErrDomSecret:         "ErrDomSecret",
+	ErrDomCPU:            "ErrDomCPU",
+	ErrDomXenAPI:         "ErrDomXenAPI",
+	ErrDomNwFilter:       "ErrDomNwFilter",
+	ErrDomHook:           "ErrDomHook",
+	ErrDomDomainSnapshot: "ErrDomDomainSnapshot",
+	ErrDomAudit:          "ErrDomAudit",
+	ErrDomSysinfo:        "ErrDomSysinfo",
+	ErrDomStreams:        "ErrDomStreams",
+	ErrDomVMWare:         "ErrDomVMWare",
+	ErrDomEvent:          "ErrDomEvent",
+	ErrDomLibXL:          "ErrDomLibXL",
+	ErrDomLocking:        "ErrDomLocking",
+	ErrDomHyperv:         "ErrDomHyperv",
+	ErrDomCapabilities:   "ErrDomCapabilities",
+	ErrDomURI:            "ErrDomURI",
+	ErrDomAuth:           "ErrDomAuth",
+	ErrDomDBus:           "ErrDomDBus",
+	ErrDomParallels:      "ErrDomParallels",
+	ErrDomDevice:         "ErrDomDevice",
+	ErrDomSSH:            "ErrDomSSH",
+	ErrDomLockspace:      "ErrDomLockspace",
+	ErrDomInitctl:        "ErrDomInitctl",
+	ErrDomIdentity:       "ErrDomIdentity",
+	ErrDomCgroup:         "ErrDomCgroup",
+	ErrDomAccess:         "ErrDomAccess",
+	ErrDomSystemd:        "ErrDomSystemd",
+	ErrDomBhyve:          "ErrDomBhyve",
+	ErrDomCrypto:         "ErrDomCrypto",
+	ErrDomFirewall:       "ErrDomFirewall",
+	ErrDomPolkit:         "ErrDomPolkit",
+}
+
+// String returns the name of the error domain constant.
+func (dom ErrorDomain) String() string {
+	if name, ok := errorDomainNames[dom]; ok {
+		return name
+	}
+
+	return "ErrorDomain(" + strconv.FormatUint(uint64(dom), 10) + ")"
+}
+
+var errorLevelNames = map[ErrorLevel]string{
+	ErrLvlNone:    "ErrLvlNone",
+	ErrLvlWarning: "ErrLvlWarning",
+	ErrLvlError:   "ErrLvlError",
+}
+
+// String returns the name of the error level constant.
+func (lvl ErrorLevel) String() string {
+	if name, ok := errorLevelNames[lvl]; ok {
+		return name
+	}
+
+	return "ErrorLevel(" + strconv.FormatUint(uint64(lvl), 10) + ")"
+}
@@ -1,7 +1,14 @@
 package libvirt
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
 	"testing"
+
+	"code.google.com/p/go-uuid/uuid"
+	"github.com/cd1/utils-golang"
 )
 
 func TestErrorNew(t *testing.T) {
@@ -9,3 +16,159 @@ func TestErrorNew(t *testing.T) {
 		t.Error("creating an error with a nil value should return nil")
 	}
 }
+
+func TestErrorConcurrentLookups(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	const lookups = 300
+
+	type lookupFunc func() error
+
+	lookupFuncs := []struct {
+		lookup lookupFunc
+		code   ErrorCode
+	}{
+		{func() error {
+			_, err := env.conn.LookupDomainByName(utils.RandomString())
+			return err
+		}, ErrNoDomain},
+		{func() error {
+			_, err := env.conn.LookupStoragePoolByName(utils.RandomString())
+			return err
+		}, ErrNoStoragePool},
+		{func() error {
+			_, err := env.conn.LookupSecretByUUID(uuid.New())
+			return err
+		}, ErrNoSecret},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(lookups)
+
+	for i := 0; i < lookups; i++ {
+		lf := lookupFuncs[i%len(lookupFuncs)]
+
+		go func() {
+			defer wg.Done()
+
+			err := lf.lookup()
+			if err == nil {
+				t.Error("an error was not returned when looking up a non-existing object")
+				return
+			}
+
+			virErr, ok := err.(*Error)
+			if !ok {
+				t.Errorf("unexpected error type; got=%T, want=%T", err, virErr)
+				return
+			}
+
+			if virErr.Code != lf.code {
+				t.Errorf("unexpected error code; got=%v, want=%v", virErr.Code, lf.code)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestErrorLastErrorUnset(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// a successful call resets the libvirt error of the thread
+	if _, err := env.conn.Hostname(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := LastError()
+	if err == nil {
+		t.Fatal("LastError returned nil when libvirt had no error")
+	}
+
+	if err.Code != ErrInternal {
+		t.Errorf("unexpected error code when libvirt had no error; got=%v, want=%v", err.Code, ErrInternal)
+	}
+}
+
+func TestErrorString(t *testing.T) {
+	if str := ErrNoDomain.String(); str != "ErrNoDomain" {
+		t.Errorf("unexpected error code string; got=%v, want=%v", str, "ErrNoDomain")
+	}
+
+	if str := ErrDomRPC.String(); str != "ErrDomRPC" {
+		t.Errorf("unexpected error domain string; got=%v, want=%v", str, "ErrDomRPC")
+	}
+
+	if str := ErrLvlWarning.String(); str != "ErrLvlWarning" {
+		t.Errorf("unexpected error level string; got=%v, want=%v", str, "ErrLvlWarning")
+	}
+
+	if str := ErrorCode(99999).String(); str != "ErrorCode(99999)" {
+		t.Errorf("unexpected unknown error code string; got=%v, want=%v", str, "ErrorCode(99999)")
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	_, err := env.conn.LookupDomainByName(utils.RandomString())
+	if err == nil {
+		t.Fatal("an error was not returned when looking up a non-existing domain")
+	}
+
+	if !errors.Is(err, ErrNoDomain) {
+		t.Errorf("error does not match the expected code; got=%v, want=%v", err, ErrNoDomain)
+	}
+
+	if errors.Is(err, ErrNoSecret) {
+		t.Errorf("error matches an unexpected code; got=%v, don't want=%v", err, ErrNoSecret)
+	}
+
+	wrapped := fmt.Errorf("wrapped: %w", err)
+
+	var virErr *Error
+	if !errors.As(wrapped, &virErr) {
+		t.Fatalf("unexpected error type; got=%T, want=%T", errors.Unwrap(wrapped), virErr)
+	}
+
+	if virErr.Op != "Connection.LookupDomainByName" {
+		t.Errorf("unexpected failed operation; got=%v, want=%v", virErr.Op, "Connection.LookupDomainByName")
+	}
+
+	if !IsNotFound(wrapped) {
+		t.Error("a wrapped \"not found\" error was not classified as such")
+	}
+
+	if IsAlreadyExists(err) || IsOperationInvalid(err) || IsRetryable(err) || IsConnectionLost(err) {
+		t.Errorf("a \"not found\" error was classified as something else: %v", err)
+	}
+
+	if IsNotFound(nil) || IsNotFound(errors.New("not a libvirt error")) {
+		t.Error("a non-libvirt error was classified as \"not found\"")
+	}
+
+	env = env.withDomain()
+
+	if err = env.dom.Create(DomCreateAutodestroy); err != nil {
+		t.Fatal(err)
+	}
+	defer env.dom.Destroy(DomDestroyDefault)
+
+	if err = env.dom.Create(DomCreateAutodestroy); !IsOperationInvalid(err) {
+		t.Errorf("starting a running domain did not return an \"invalid operation\" error: %v", err)
+	}
+
+	if !IsConnectionLost(&Error{Code: ErrSystem, Domain: ErrDomRPC}) {
+		t.Error("an RPC system error was not classified as a lost connection")
+	}
+
+	if !IsRetryable(&Error{Code: ErrAgentUnresponsive}) {
+		t.Error("an unresponsive agent error was not classified as retryable")
+	}
+}
@@ -0,0 +1,217 @@
+package libvirt
+
+// #include <libvirt/libvirt.h>
+import "C"
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// eventChannelSize is the number of events which can be buffered in the
+// channels returned by the "Subscribe*" functions.
+const eventChannelSize = 64
+
+var (
+	eventLoopErr     error
+	eventLoopOnce    sync.Once
+	eventLoopRunning int32
+)
+
+// EventRegisterDefaultImpl registers a default event implementation based on
+// the poll() system call. Once registered, the application has to invoke
+// EventRunDefaultImpl in a loop to process events. Failure to do so may result
+// in connections being closed unexpectedly as a result of keepalive timeout.
+// The default event loop fully supports handle and timeout events, but only
+// wakes up on events registered by libvirt API calls such as
+// RegisterCloseCallback. It must be registered before opening any connection
+// which should deliver events.
+func EventRegisterDefaultImpl() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cRet := C.virEventRegisterDefaultImpl()
+	ret := int32(cRet)
+
+	if ret == -1 {
+		return LastError()
+	}
+
+	return nil
+}
+
+// EventRunDefaultImpl runs one iteration of the event loop. Applications will
+// generally want to have a goroutine which invokes this method in an infinite
+// loop. See also StartEventLoop.
+func EventRunDefaultImpl() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cRet := C.virEventRunDefaultImpl()
+	ret := int32(cRet)
+
+	if ret == -1 {
+		return LastError()
+	}
+
+	return nil
+}
+
+// StartEventLoop registers the default event implementation and starts a
+// goroutine which runs it for the rest of the program. It may be called many
+// times, but the event loop will be started only once. It should be called
+// before opening any connection which should deliver events (e.g. keepalive,
+// close callbacks).
+func StartEventLoop() error {
+	eventLoopOnce.Do(func() {
+		if err := EventRegisterDefaultImpl(); err != nil {
+			eventLoopErr = err
+			return
+		}
+
+		atomic.StoreInt32(&eventLoopRunning, 1)
+
+		go func() {
+			for {
+				EventRunDefaultImpl()
+			}
+		}()
+	})
+
+	return eventLoopErr
+}
+
+// EventLoopRunning determines whether the event loop has been started by
+// StartEventLoop.
+func EventLoopRunning() bool {
+	return atomic.LoadInt32(&eventLoopRunning) == 1
+}
+
+// EventSubscription represents the registration of a channel which receives
+// libvirt events. It must be closed when the events are no longer needed.
+type EventSubscription struct {
+	close func() error
+}
+
+// Close stops delivering events. The events channel is closed afterwards.
+func (sub *EventSubscription) Close() error {
+	return sub.close()
+}
+
+// nonLifecycleEvent is the value given, in the lifecycle event types, to the
+// events which libvirt reports through another callback (e.g. the storage
+// pool refresh).
+const nonLifecycleEvent = -1
+
+// eventHandler is embedded by the handlers which deliver events to a channel.
+// A handler may be registered for more than one libvirt event, so "done"
+// (which usually closes the channel) is only called after libvirt frees all of
+// them.
+type eventHandler struct {
+	refs int32
+	done func()
+}
+
+func (h *eventHandler) free() {
+	if atomic.AddInt32(&h.refs, -1) == 0 {
+		h.done()
+	}
+}
+
+func (h *eventHandler) base() *eventHandler {
+	return h
+}
+
+// eventSubscriber is implemented by the handlers which embed eventHandler.
+type eventSubscriber interface {
+	callbackFreer
+	base() *eventHandler
+}
+
+// eventRegisterFunc registers a libvirt event callback, which finds its
+// handler by "callbackID" (see registerCallback). It returns the ID of the
+// callback in libvirt, or -1.
+type eventRegisterFunc func(callbackID C.long) C.int
+
+// eventDeregisterFunc removes a libvirt event callback, identified by the value
+// returned when it was registered.
+type eventDeregisterFunc func(libvirtCallbackID C.int) C.int
+
+// subscribeEvents registers "handler" with every function in "registers", and
+// returns a subscription which removes all of them with "deregister". If any
+// registration fails, the previous ones are removed. "what" describes the
+// events in the logs. It must be called by the "Subscribe*" functions, which
+// are the operations reported in the errors, and which must log the errors.
+func (conn Connection) subscribeEvents(what string, handler eventSubscriber, deregister eventDeregisterFunc, registers ...eventRegisterFunc) (*EventSubscription, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	base := handler.base()
+	base.refs = int32(len(registers))
+
+	var libvirtCallbackIDs []int32
+
+	unsubscribe := func() error {
+		var err error
+
+		for _, id := range libvirtCallbackIDs {
+			if deregisterErr := conn.deregisterEvent(what, deregister, id); err == nil {
+				err = deregisterErr
+			}
+		}
+
+		return err
+	}
+
+	conn.log.Printf("subscribing to %v events...\n", what)
+
+	for i, register := range registers {
+		callbackID := registerCallback(handler)
+
+		cRet := register(C.long(callbackID))
+		ret := int32(cRet)
+
+		if ret == -1 {
+			err := LastError()
+			err.Op = callerName(2)
+
+			// libvirt won't free the callbacks which weren't registered
+			unregisterCallback(callbackID)
+			for j := i; j < len(registers); j++ {
+				base.free()
+			}
+
+			unsubscribe()
+			return nil, err
+		}
+
+		libvirtCallbackIDs = append(libvirtCallbackIDs, ret)
+	}
+
+	conn.log.Printf("subscribed to %v events\n", what)
+
+	return &EventSubscription{close: unsubscribe}, nil
+}
+
+// deregisterEvent removes an event callback with "deregister", identified by
+// the value returned by libvirt when it was registered.
+func (conn Connection) deregisterEvent(what string, deregister eventDeregisterFunc, libvirtCallbackID int32) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	conn.log.Printf("unsubscribing from %v events...\n", what)
+	cRet := deregister(C.int(libvirtCallbackID))
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	conn.log.Printf("unsubscribed from %v events\n", what)
+
+	return nil
+}
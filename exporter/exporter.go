@@ -0,0 +1,343 @@
+// Package exporter exposes metrics about a libvirt host, its domains and its
+// storage pools in the Prometheus text format.
+package exporter
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cd1/libvirt-golang"
+	"github.com/cd1/libvirt-golang/internal/promtext"
+)
+
+// Exporter collects metrics from a libvirt connection every time they're
+// requested. It's safe for concurrent use, but scrapes are serialized.
+type Exporter struct {
+	conn  libvirt.Connection
+	mutex sync.Mutex
+}
+
+// New creates an exporter which collects metrics from "conn". The connection
+// may be read-only, and it's not closed by the exporter.
+func New(conn libvirt.Connection) *Exporter {
+	return &Exporter{
+		conn: conn,
+	}
+}
+
+// ServeHTTP collects the metrics and writes them as an HTTP response, so the
+// exporter can be used as the handler of the "/metrics" endpoint.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.WriteTo(w)
+}
+
+// WriteTo collects the metrics and writes them to "w" in the Prometheus text
+// format. Errors reading a single object (e.g. a domain which was undefined
+// during the scrape) are ignored; if the objects can't be listed at all,
+// "libvirt_up" is set to 0.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	set := newMetricSet()
+
+	up := 1.0
+	if err := e.collectHost(set); err != nil {
+		up = 0
+	}
+	if err := e.collectDomains(set); err != nil {
+		up = 0
+	}
+	if err := e.collectStoragePools(set); err != nil {
+		up = 0
+	}
+
+	set.add("libvirt_up", "Whether the last scrape of libvirt was successful.", gauge, up)
+
+	return set.writeTo(w)
+}
+
+// collectHost collects the host metrics.
+func (e *Exporter) collectHost(set *metricSet) error {
+	info, err := e.conn.NodeInfo()
+	if err != nil {
+		return err
+	}
+
+	set.add("libvirt_node_cpus", "Number of active CPUs of the host.", gauge, float64(info.CPUs))
+	set.add("libvirt_node_cpu_mhz", "Expected CPU frequency of the host, in MHz.", gauge, float64(info.MHz))
+	set.add("libvirt_node_memory_total_bytes", "Total memory of the host, in bytes.", gauge, float64(info.Memory*1024))
+
+	if free, err := e.conn.NodeFreeMemory(); err == nil {
+		set.add("libvirt_node_memory_free_bytes", "Free memory of the host, in bytes.", gauge, float64(free))
+	}
+
+	if stats, err := e.conn.NodeCPUStats(libvirt.NodeCPUStatsAllCPUs); err == nil {
+		const name = "libvirt_node_cpu_seconds_total"
+		const help = "Time spent by the host CPUs in each mode, in seconds."
+
+		set.add(name, help, counter, nanoseconds(stats.Kernel), "mode", "kernel")
+		set.add(name, help, counter, nanoseconds(stats.User), "mode", "user")
+		set.add(name, help, counter, nanoseconds(stats.Idle), "mode", "idle")
+		set.add(name, help, counter, nanoseconds(stats.IOWait), "mode", "iowait")
+	}
+
+	return nil
+}
+
+// collectDomains collects the metrics of every domain.
+func (e *Exporter) collectDomains(set *metricSet) error {
+	domains, err := e.conn.ListDomains(libvirt.DomListAll)
+	if err != nil {
+		return err
+	}
+
+	for _, dom := range domains {
+		collectDomain(set, dom)
+		dom.Free()
+	}
+
+	return nil
+}
+
+// collectDomain collects the metrics of "dom".
+func collectDomain(set *metricSet, dom libvirt.Domain) {
+	name, err := dom.Name()
+	if err != nil {
+		return
+	}
+
+	uuid, err := dom.UUID()
+	if err != nil {
+		return
+	}
+
+	labels := []string{"domain", name, "uuid", uuid}
+
+	state, err := dom.InfoState()
+	if err != nil {
+		return
+	}
+
+	set.add("libvirt_domain_state", "State of the domain (see virDomainState).", gauge, float64(state), labels...)
+
+	if maxMemory, err := dom.InfoMaxMemory(); err == nil {
+		set.add("libvirt_domain_memory_max_bytes", "Maximum memory of the domain, in bytes.", gauge, float64(maxMemory*1024), labels...)
+	}
+
+	if memory, err := dom.InfoMemory(); err == nil {
+		set.add("libvirt_domain_memory_bytes", "Memory used by the domain, in bytes.", gauge, float64(memory*1024), labels...)
+	}
+
+	if vcpus, err := dom.InfoVCPUs(); err == nil {
+		set.add("libvirt_domain_vcpus", "Number of virtual CPUs of the domain.", gauge, float64(vcpus), labels...)
+	}
+
+	if cpuTime, err := dom.InfoCPUTime(); err == nil {
+		set.add("libvirt_domain_cpu_seconds_total", "CPU time used by the domain, in seconds.", counter, nanoseconds(cpuTime), labels...)
+	}
+
+	if state != libvirt.DomStateRunning && state != libvirt.DomStatePaused {
+		return
+	}
+
+	xmlDesc, err := dom.XML(libvirt.DomXMLDefault)
+	if err != nil {
+		return
+	}
+
+	var devices domainDevices
+	if err = xml.Unmarshal([]byte(xmlDesc), &devices); err != nil {
+		return
+	}
+
+	for _, disk := range devices.Disks {
+		stats, err := dom.BlockStats(disk.Target.Dev)
+		if err != nil {
+			continue
+		}
+
+		diskLabels := append(labels[:len(labels):len(labels)], "device", disk.Target.Dev)
+
+		set.addIfSupported("libvirt_domain_block_read_requests_total", "Number of read requests of the domain block device.", counter, stats.ReadRequests, diskLabels...)
+		set.addIfSupported("libvirt_domain_block_read_bytes_total", "Number of bytes read from the domain block device.", counter, stats.ReadBytes, diskLabels...)
+		set.addIfSupported("libvirt_domain_block_write_requests_total", "Number of write requests of the domain block device.", counter, stats.WriteRequests, diskLabels...)
+		set.addIfSupported("libvirt_domain_block_write_bytes_total", "Number of bytes written to the domain block device.", counter, stats.WriteBytes, diskLabels...)
+		set.addIfSupported("libvirt_domain_block_errors_total", "Number of errors of the domain block device.", counter, stats.Errors, diskLabels...)
+	}
+
+	for _, iface := range devices.Interfaces {
+		stats, err := dom.InterfaceStats(iface.Target.Dev)
+		if err != nil {
+			continue
+		}
+
+		ifaceLabels := append(labels[:len(labels):len(labels)], "device", iface.Target.Dev)
+
+		set.addIfSupported("libvirt_domain_interface_receive_bytes_total", "Number of bytes received by the domain network interface.", counter, stats.RxBytes, ifaceLabels...)
+		set.addIfSupported("libvirt_domain_interface_receive_packets_total", "Number of packets received by the domain network interface.", counter, stats.RxPackets, ifaceLabels...)
+		set.addIfSupported("libvirt_domain_interface_receive_errors_total", "Number of receive errors of the domain network interface.", counter, stats.RxErrors, ifaceLabels...)
+		set.addIfSupported("libvirt_domain_interface_receive_drops_total", "Number of received packets dropped by the domain network interface.", counter, stats.RxDrop, ifaceLabels...)
+		set.addIfSupported("libvirt_domain_interface_transmit_bytes_total", "Number of bytes transmitted by the domain network interface.", counter, stats.TxBytes, ifaceLabels...)
+		set.addIfSupported("libvirt_domain_interface_transmit_packets_total", "Number of packets transmitted by the domain network interface.", counter, stats.TxPackets, ifaceLabels...)
+		set.addIfSupported("libvirt_domain_interface_transmit_errors_total", "Number of transmit errors of the domain network interface.", counter, stats.TxErrors, ifaceLabels...)
+		set.addIfSupported("libvirt_domain_interface_transmit_drops_total", "Number of transmitted packets dropped by the domain network interface.", counter, stats.TxDrop, ifaceLabels...)
+	}
+}
+
+// collectStoragePools collects the metrics of every storage pool.
+func (e *Exporter) collectStoragePools(set *metricSet) error {
+	pools, err := e.conn.ListStoragePools(libvirt.PoolListAll)
+	if err != nil {
+		return err
+	}
+
+	for _, pool := range pools {
+		collectStoragePool(set, pool)
+		pool.Free()
+	}
+
+	return nil
+}
+
+// collectStoragePool collects the metrics of "pool".
+func collectStoragePool(set *metricSet, pool libvirt.StoragePool) {
+	name, err := pool.Name()
+	if err != nil {
+		return
+	}
+
+	if capacity, err := pool.InfoCapacity(); err == nil {
+		set.add("libvirt_storage_pool_capacity_bytes", "Capacity of the storage pool, in bytes.", gauge, float64(capacity), "pool", name)
+	}
+
+	if allocation, err := pool.InfoAllocation(); err == nil {
+		set.add("libvirt_storage_pool_allocation_bytes", "Space allocated in the storage pool, in bytes.", gauge, float64(allocation), "pool", name)
+	}
+
+	if available, err := pool.InfoAvailable(); err == nil {
+		set.add("libvirt_storage_pool_available_bytes", "Space available in the storage pool, in bytes.", gauge, float64(available), "pool", name)
+	}
+}
+
+// domainDevices is the part of a domain XML description used to find its
+// block devices and network interfaces.
+type domainDevices struct {
+	Disks []struct {
+		Target struct {
+			Dev string `xml:"dev,attr"`
+		} `xml:"target"`
+	} `xml:"devices>disk"`
+	Interfaces []struct {
+		Target struct {
+			Dev string `xml:"dev,attr"`
+		} `xml:"target"`
+	} `xml:"devices>interface"`
+}
+
+// nanoseconds converts a time in nanoseconds to seconds.
+func nanoseconds(ns uint64) float64 {
+	return float64(ns) / 1e9
+}
+
+// metricType is the type of a metric, as declared in the "# TYPE" line.
+type metricType string
+
+// Possible values for metricType.
+const (
+	counter metricType = "counter"
+	gauge   metricType = "gauge"
+)
+
+// metric is a set of samples with the same name.
+type metric struct {
+	help    string
+	typ     metricType
+	samples []string
+}
+
+// metricSet holds the metrics collected in a single scrape.
+type metricSet struct {
+	metrics map[string]*metric
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{
+		metrics: make(map[string]*metric),
+	}
+}
+
+// add adds a sample to the metric "name". "labels" is a list of label names
+// and values, alternately.
+func (set *metricSet) add(name string, help string, typ metricType, value float64, labels ...string) {
+	m, ok := set.metrics[name]
+	if !ok {
+		m = &metric{help: help, typ: typ}
+		set.metrics[name] = m
+	}
+
+	var sample strings.Builder
+	sample.WriteString(name)
+
+	if len(labels) > 0 {
+		sample.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				sample.WriteByte(',')
+			}
+			sample.WriteString(labels[i])
+			sample.WriteString("=")
+			sample.WriteString(promtext.QuoteLabel(labels[i+1]))
+		}
+		sample.WriteByte('}')
+	}
+
+	sample.WriteByte(' ')
+	sample.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+
+	m.samples = append(m.samples, sample.String())
+}
+
+// addIfSupported adds a sample like "add", unless "value" is negative, which
+// means the hypervisor doesn't support it.
+func (set *metricSet) addIfSupported(name string, help string, typ metricType, value int64, labels ...string) {
+	if value < 0 {
+		return
+	}
+
+	set.add(name, help, typ, float64(value), labels...)
+}
+
+// writeTo writes the metrics to "w", sorted by name.
+func (set *metricSet) writeTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(set.metrics))
+	for name := range set.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := &promtext.CountingWriter{W: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, name := range names {
+		m := set.metrics[name]
+
+		fmt.Fprintf(bw, "# HELP %v %v\n", name, m.help)
+		fmt.Fprintf(bw, "# TYPE %v %v\n", name, m.typ)
+		for _, sample := range m.samples {
+			fmt.Fprintln(bw, sample)
+		}
+	}
+
+	err := bw.Flush()
+
+	return cw.N, err
+}
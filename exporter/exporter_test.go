@@ -0,0 +1,63 @@
+package exporter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cd1/libvirt-golang"
+)
+
+const testConnectionURI = "test:///default"
+
+func TestExporter(t *testing.T) {
+	conn, err := libvirt.Open(testConnectionURI, libvirt.ReadOnly, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	if _, err = New(conn).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+
+	// the "test" driver comes with a running domain called "test" and a
+	// storage pool called "default-pool"
+	for _, want := range []string{
+		"libvirt_up 1\n",
+		"# TYPE libvirt_node_cpus gauge\n",
+		"libvirt_node_memory_total_bytes ",
+		`libvirt_domain_state{domain="test",uuid="`,
+		"# TYPE libvirt_domain_cpu_seconds_total counter\n",
+		`libvirt_domain_vcpus{domain="test",`,
+		`libvirt_storage_pool_capacity_bytes{pool="default-pool"} `,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("metric not found; got=%q, want=%q", output, want)
+		}
+	}
+}
+
+func TestExporterHTTP(t *testing.T) {
+	conn, err := libvirt.Open(testConnectionURI, libvirt.ReadOnly, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rec := httptest.NewRecorder()
+	New(conn).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("unexpected content type; got=%v, want=%v", contentType, "text/plain")
+	}
+
+	if body := rec.Body.String(); !strings.Contains(body, "libvirt_up 1\n") {
+		t.Errorf("unexpected response body; got=%q, want to contain=%q", body, "libvirt_up 1")
+	}
+}
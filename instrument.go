@@ -0,0 +1,126 @@
+package libvirt
+
+// #include <libvirt/virterror.h>
+import "C"
+import (
+	"time"
+)
+
+// CallInfo describes a call to libvirt, as reported to an
+// InstrumentationHook.
+type CallInfo struct {
+	// Operation is the name of the method which made the call (e.g.
+	// "Connection.LookupDomainByName").
+	Operation string
+
+	// Object is the type of the object which made the call (e.g. "domain").
+	Object string
+
+	// Name and UUID identify the object which made the call, if they were
+	// known when the object was obtained (e.g. when looking it up by name).
+	Name string
+	UUID string
+
+	// Duration is how long the call took.
+	Duration time.Duration
+
+	// Code is the libvirt error code returned by the call; ErrOK means
+	// the call succeeded (or failed before reaching libvirt).
+	Code ErrorCode
+}
+
+// InstrumentationHook is called after every call to libvirt made by a
+// connection or by any of its objects. It's called on the goroutine which made
+// the call, so it should return quickly.
+type InstrumentationHook func(info CallInfo)
+
+// SetInstrumentationHook sets the function to be called after every call to
+// libvirt made by the connection or by any object obtained from it. A nil
+// hook removes the current one.
+func (conn Connection) SetInstrumentationHook(hook InstrumentationHook) {
+	conn.log.shared.hook.Store(hook)
+}
+
+// libvirtCall is a call to libvirt which is logged and reported to the
+// instrumentation hook (see logger.startCall).
+type libvirtCall struct {
+	log   *logger
+	start time.Time
+	code  ErrorCode
+}
+
+// startCall must be called by every instrumented method right after locking
+// the OS thread, deferring "finish" on the result:
+//
+//	call := x.log.startCall()
+//	defer call.finish()
+//
+// It resets the libvirt error of the thread, so LastError doesn't report an
+// error left by a previous call. The failures of the call must be logged with
+// "call.error", which also records their code, or at least recorded with
+// "call.record".
+func (l *logger) startCall() *libvirtCall {
+	C.virResetLastError()
+
+	return &libvirtCall{
+		log:   l,
+		start: time.Now(),
+		code:  ErrOK,
+	}
+}
+
+// record records "err" as the result of the call, without logging it.
+func (c *libvirtCall) record(err error) {
+	c.code = errorCode(err)
+}
+
+// error logs "err" (see logger.error) and records it as the result of the
+// call.
+func (c *libvirtCall) error(err error) {
+	c.record(err)
+	c.log.errorFrom(err, 3)
+}
+
+// finish logs how long the call made by the caller took, and reports it to
+// the instrumentation hook, if any. Nothing is done if there's no hook and the
+// debug messages are discarded.
+func (c *libvirtCall) finish() {
+	hook, _ := c.log.shared.hook.Load().(InstrumentationHook)
+	debug := c.log.debugEnabled()
+
+	if hook == nil && !debug {
+		return
+	}
+
+	duration := time.Since(c.start)
+	op := callerName(2)
+
+	if debug {
+		c.log.output().Debug("call finished", append(c.log.fields[:len(c.log.fields):len(c.log.fields)], Field{FieldOperation, op}, Field{FieldDuration, duration})...)
+	}
+
+	if hook == nil {
+		return
+	}
+
+	info := CallInfo{
+		Operation: op,
+		Duration:  duration,
+		Code:      c.code,
+	}
+
+	for _, f := range c.log.fields {
+		value, _ := f.Value.(string)
+
+		switch f.Key {
+		case FieldObject:
+			info.Object = value
+		case FieldName:
+			info.Name = value
+		case FieldUUID:
+			info.UUID = value
+		}
+	}
+
+	hook(info)
+}
@@ -0,0 +1,145 @@
+package libvirt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cd1/utils-golang"
+)
+
+func TestInstrumentationHook(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	var mutex sync.Mutex
+	var calls []CallInfo
+
+	env.conn.SetInstrumentationHook(func(info CallInfo) {
+		mutex.Lock()
+		calls = append(calls, info)
+		mutex.Unlock()
+	})
+	defer env.conn.SetInstrumentationHook(nil)
+
+	name := utils.RandomString()
+	if _, err := env.conn.LookupDomainByName(name); err == nil {
+		t.Fatal("an error was not returned when looking up a non-existing domain")
+	}
+
+	if _, err := env.dom.Name(); err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(calls) != 2 {
+		t.Fatalf("unexpected number of instrumented calls; got=%v, want=%v", len(calls), 2)
+	}
+
+	if calls[0].Operation != "Connection.LookupDomainByName" || calls[0].Code != ErrNoDomain {
+		t.Errorf("unexpected failed call; got=%+v, want operation=%v, code=%v", calls[0], "Connection.LookupDomainByName", ErrNoDomain)
+	}
+
+	if calls[1].Operation != "Domain.Name" || calls[1].Object != objectDomain || calls[1].Code != ErrOK {
+		t.Errorf("unexpected successful call; got=%+v, want operation=%v, object=%v, code=%v", calls[1], "Domain.Name", objectDomain, ErrOK)
+	}
+}
+
+func TestInstrumentationStaleError(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	// both calls must run on the same OS thread, which holds the libvirt error
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var calls []CallInfo
+
+	env.conn.SetInstrumentationHook(func(info CallInfo) {
+		calls = append(calls, info)
+	})
+	defer env.conn.SetInstrumentationHook(nil)
+
+	if _, err := env.conn.LookupDomainByName(utils.RandomString()); err == nil {
+		t.Fatal("an error was not returned when looking up a non-existing domain")
+	}
+
+	// this call returns before reaching libvirt
+	if _, err := env.conn.NodeCellsFreeMemory(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("unexpected number of instrumented calls; got=%v, want=%v", len(calls), 2)
+	}
+
+	if calls[1].Code != ErrOK {
+		t.Errorf("unexpected error code of a call which didn't reach libvirt; got=%v, want=%v", calls[1].Code, ErrOK)
+	}
+}
+
+func TestInstrumentationLog(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	logger := new(testLogger)
+	env.conn.SetLogger(logger)
+
+	if _, err := env.dom.Name(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !logger.find("debug", FieldOperation, "Domain.Name") {
+		t.Fatalf("the finished call was not logged: %v", logger.entries)
+	}
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	for _, e := range logger.entries {
+		if e.fields[FieldOperation] == "Domain.Name" {
+			if _, ok := e.fields[FieldDuration].(time.Duration); !ok {
+				t.Errorf("the call duration was not logged as a field: %v", e)
+			}
+		}
+	}
+
+	if NewWriterLogger(ioutil.Discard).(DebugEnabler).DebugEnabled() {
+		t.Error("a logger which discards its output should not enable debug messages")
+	}
+}
+
+func TestInstrumentationMetrics(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	metrics := NewMetrics(nil)
+	env.conn.SetInstrumentationHook(metrics.Hook)
+	defer env.conn.SetInstrumentationHook(nil)
+
+	for i := 0; i < 3; i++ {
+		env.conn.LookupDomainByName(utils.RandomString())
+	}
+
+	var buf bytes.Buffer
+	if _, err := metrics.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{
+		`libvirt_calls_total{operation="Connection.LookupDomainByName",object="connection",code="ErrNoDomain"} 3`,
+		`libvirt_call_duration_seconds_bucket{operation="Connection.LookupDomainByName",le="+Inf"} 3`,
+		`libvirt_call_duration_seconds_count{operation="Connection.LookupDomainByName"} 3`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("metric not found; got=%q, want=%q", output, want)
+		}
+	}
+}
@@ -4,7 +4,9 @@ package libvirt
 // #include <libvirt/libvirt.h>
 import "C"
 import (
-	"log"
+	"runtime"
+	"unicode/utf8"
+	"unsafe"
 )
 
 // InterfaceListFlag defines a filter when listing network interfaces.
@@ -17,22 +19,44 @@ const (
 	IfaceListInactive InterfaceListFlag = C.VIR_CONNECT_LIST_INTERFACES_INACTIVE
 )
 
+// InterfaceXMLFlag defines how the XML content should be read from an
+// interface.
+type InterfaceXMLFlag uint32
+
+// Possible values for InterfaceXMLFlag.
+const (
+	IfaceXMLDefault  InterfaceXMLFlag = 0
+	IfaceXMLInactive InterfaceXMLFlag = C.VIR_INTERFACE_XML_INACTIVE
+)
+
 // Interface holds a libvirt network interface. There are no exported fields.
 type Interface struct {
-	log          *log.Logger
+	log          *logger
+	ref          *objectRef
 	virInterface C.virInterfacePtr
 }
 
 // Free frees the interface object. The interface itself is unaltered. The data
 // structure is freed and should not be used thereafter.
 func (iface Interface) Free() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(iface.ref)
+	call := iface.log.startCall()
+	defer call.finish()
+
+	if !iface.ref.release() {
+		iface.log.Println("interface object already freed")
+		return nil
+	}
+
 	iface.log.Println("freeing interface object...")
 	cRet := C.virInterfaceFree(iface.virInterface)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		iface.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -40,3 +64,102 @@ func (iface Interface) Free() error {
 
 	return nil
 }
+
+// Name returns the name of the interface (e.g. "eth0").
+func (iface Interface) Name() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(iface.ref)
+	call := iface.log.startCall()
+	defer call.finish()
+
+	iface.log.Println("reading interface name...")
+	cName := C.virInterfaceGetName(iface.virInterface)
+
+	if cName == nil {
+		err := LastError()
+		call.error(err)
+		return "", err
+	}
+
+	name := C.GoString(cName)
+	iface.log.Printf("name: %v\n", name)
+
+	return name, nil
+}
+
+// MACString returns the MAC address of the interface, as a string.
+func (iface Interface) MACString() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(iface.ref)
+	call := iface.log.startCall()
+	defer call.finish()
+
+	iface.log.Println("reading interface MAC address...")
+	cMAC := C.virInterfaceGetMACString(iface.virInterface)
+
+	if cMAC == nil {
+		err := LastError()
+		call.error(err)
+		return "", err
+	}
+
+	mac := C.GoString(cMAC)
+	iface.log.Printf("MAC address: %v\n", mac)
+
+	return mac, nil
+}
+
+// XML provides an XML description of the interface. The description may be
+// reused later to redefine the interface.
+func (iface Interface) XML(flags InterfaceXMLFlag) (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(iface.ref)
+	call := iface.log.startCall()
+	defer call.finish()
+
+	iface.log.with(Field{FieldFlags, flags}).Println("reading interface XML...")
+	cXML := C.virInterfaceGetXMLDesc(iface.virInterface, C.uint(flags))
+
+	if cXML == nil {
+		err := LastError()
+		call.error(err)
+		return "", err
+	}
+	defer C.free(unsafe.Pointer(cXML))
+
+	xml := C.GoString(cXML)
+
+	iface.log.Printf("XML length: %v runes\n", utf8.RuneCountInString(xml))
+
+	return xml, nil
+}
+
+// Ref increments the reference count on the interface. For each additional
+// call to this method, there shall be a corresponding call to Free to release
+// the reference count, once the caller no longer needs the reference to this
+// object.
+func (iface Interface) Ref() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(iface.ref)
+	call := iface.log.startCall()
+	defer call.finish()
+
+	iface.log.Println("incrementing interface's reference count...")
+	cRet := C.virInterfaceRef(iface.virInterface)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	iface.ref.acquire()
+	iface.log.Println("reference count incremented")
+
+	return nil
+}
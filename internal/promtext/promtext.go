@@ -0,0 +1,30 @@
+// Package promtext holds the helpers shared by the code which writes metrics in
+// the Prometheus text format.
+package promtext
+
+import (
+	"io"
+	"strings"
+)
+
+// QuoteLabel quotes a Prometheus label value.
+func QuoteLabel(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+
+	return `"` + value + `"`
+}
+
+// CountingWriter counts the bytes written to "W".
+type CountingWriter struct {
+	W io.Writer
+	N int64
+}
+
+func (cw *CountingWriter) Write(p []byte) (int, error) {
+	n, err := cw.W.Write(p)
+	cw.N += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,231 @@
+package libvirt
+
+import (
+	"errors"
+)
+
+// ErrStopIteration can be returned by the function passed to the "ForEach"
+// methods to stop the iteration without an error.
+var ErrStopIteration = errors.New("stop iteration")
+
+// DomainSummary holds the most common attributes of a domain, as fetched by
+// ForEachDomainSummary.
+type DomainSummary struct {
+	Name  string
+	UUID  string
+	State DomainState
+}
+
+// StoragePoolSummary holds the most common attributes of a storage pool, as
+// fetched by ForEachStoragePoolSummary.
+type StoragePoolSummary struct {
+	Name  string
+	UUID  string
+	State StoragePoolState
+}
+
+// freer is implemented by every object which must be freed.
+type freer interface {
+	Free() error
+}
+
+// visit calls "fn" with the index of every object in "objs", until "fn" returns
+// an error, and frees all of them, whether they were visited or not. It returns
+// the first error returned by "fn" (unless it's ErrStopIteration) or by Free.
+func visit(objs []freer, fn func(i int) error) error {
+	var err error
+
+	for i, obj := range objs {
+		if err == nil {
+			err = fn(i)
+		}
+
+		if freeErr := obj.Free(); freeErr != nil && err == nil {
+			err = freeErr
+		}
+	}
+
+	if err == ErrStopIteration {
+		return nil
+	}
+
+	return err
+}
+
+// skipNotFound returns nil if "err" reports a missing object, so the iteration
+// skips objects removed after they were listed. Otherwise, it returns "err".
+func skipNotFound(err error) error {
+	if IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// ForEachDomain calls "fn" for every domain which matches "flags", until it
+// returns an error. Each domain is freed after "fn" returns, so it must not be
+// used afterwards; call Ref to keep it. The error returned by "fn" is returned,
+// except for ErrStopIteration.
+func (conn Connection) ForEachDomain(flags DomainListFlag, fn func(dom Domain) error) error {
+	domains, err := conn.ListDomains(flags)
+	if err != nil {
+		return err
+	}
+
+	objs := make([]freer, len(domains))
+	for i, dom := range domains {
+		objs[i] = dom
+	}
+
+	return visit(objs, func(i int) error {
+		return fn(domains[i])
+	})
+}
+
+// ForEachDomainSummary works like ForEachDomain, but it also passes the name,
+// UUID and state of each domain to "fn". Domains which disappear before their
+// summary is read (e.g. transient domains which were shut down) are skipped.
+func (conn Connection) ForEachDomainSummary(flags DomainListFlag, fn func(dom Domain, summary DomainSummary) error) error {
+	return conn.ForEachDomain(flags, func(dom Domain) error {
+		var summary DomainSummary
+		var err error
+
+		if summary.Name, err = dom.Name(); err != nil {
+			return skipNotFound(err)
+		}
+
+		if summary.UUID, err = dom.UUID(); err != nil {
+			return skipNotFound(err)
+		}
+
+		if summary.State, err = dom.InfoState(); err != nil {
+			return skipNotFound(err)
+		}
+
+		return fn(dom, summary)
+	})
+}
+
+// ForEachStoragePool calls "fn" for every storage pool which matches "flags",
+// until it returns an error. Each pool is freed after "fn" returns, so it must
+// not be used afterwards; call Ref to keep it. The error returned by "fn" is
+// returned, except for ErrStopIteration.
+func (conn Connection) ForEachStoragePool(flags StoragePoolListFlag, fn func(pool StoragePool) error) error {
+	pools, err := conn.ListStoragePools(flags)
+	if err != nil {
+		return err
+	}
+
+	objs := make([]freer, len(pools))
+	for i, pool := range pools {
+		objs[i] = pool
+	}
+
+	return visit(objs, func(i int) error {
+		return fn(pools[i])
+	})
+}
+
+// ForEachStoragePoolSummary works like ForEachStoragePool, but it also passes
+// the name, UUID and state of each pool to "fn". Pools which disappear before
+// their summary is read are skipped.
+func (conn Connection) ForEachStoragePoolSummary(flags StoragePoolListFlag, fn func(pool StoragePool, summary StoragePoolSummary) error) error {
+	return conn.ForEachStoragePool(flags, func(pool StoragePool) error {
+		var summary StoragePoolSummary
+		var err error
+
+		if summary.Name, err = pool.Name(); err != nil {
+			return skipNotFound(err)
+		}
+
+		if summary.UUID, err = pool.UUID(); err != nil {
+			return skipNotFound(err)
+		}
+
+		if summary.State, err = pool.InfoState(); err != nil {
+			return skipNotFound(err)
+		}
+
+		return fn(pool, summary)
+	})
+}
+
+// ForEachSecret calls "fn" for every secret which matches "flags", until it
+// returns an error. Each secret is freed after "fn" returns, so it must not be
+// used afterwards; call Ref to keep it. The error returned by "fn" is returned,
+// except for ErrStopIteration.
+func (conn Connection) ForEachSecret(flags SecretListFlag, fn func(sec Secret) error) error {
+	secrets, err := conn.ListSecrets(flags)
+	if err != nil {
+		return err
+	}
+
+	objs := make([]freer, len(secrets))
+	for i, sec := range secrets {
+		objs[i] = sec
+	}
+
+	return visit(objs, func(i int) error {
+		return fn(secrets[i])
+	})
+}
+
+// ForEachInterface calls "fn" for every network interface which matches
+// "flags", until it returns an error. Each interface is freed after "fn"
+// returns, so it must not be used afterwards. The error returned by "fn" is
+// returned, except for ErrStopIteration.
+func (conn Connection) ForEachInterface(flags InterfaceListFlag, fn func(iface Interface) error) error {
+	ifaces, err := conn.ListInterfaces(flags)
+	if err != nil {
+		return err
+	}
+
+	objs := make([]freer, len(ifaces))
+	for i, iface := range ifaces {
+		objs[i] = iface
+	}
+
+	return visit(objs, func(i int) error {
+		return fn(ifaces[i])
+	})
+}
+
+// ForEachStorageVolume calls "fn" for every storage volume in the pool, until
+// it returns an error. Each volume is freed after "fn" returns, so it must not
+// be used afterwards; call Ref to keep it. The error returned by "fn" is
+// returned, except for ErrStopIteration.
+func (pool StoragePool) ForEachStorageVolume(fn func(vol StorageVolume) error) error {
+	vols, err := pool.ListStorageVolumes()
+	if err != nil {
+		return err
+	}
+
+	objs := make([]freer, len(vols))
+	for i, vol := range vols {
+		objs[i] = vol
+	}
+
+	return visit(objs, func(i int) error {
+		return fn(vols[i])
+	})
+}
+
+// ForEachSnapshot calls "fn" for every snapshot of the domain which matches
+// "flags", until it returns an error. Each snapshot is freed after "fn"
+// returns, so it must not be used afterwards; call Ref to keep it. The error
+// returned by "fn" is returned, except for ErrStopIteration.
+func (dom Domain) ForEachSnapshot(flags SnapshotListFlag, fn func(snap Snapshot) error) error {
+	snaps, err := dom.ListSnapshots(flags)
+	if err != nil {
+		return err
+	}
+
+	objs := make([]freer, len(snaps))
+	for i, snap := range snaps {
+		objs[i] = snap
+	}
+
+	return visit(objs, func(i int) error {
+		return fn(snaps[i])
+	})
+}
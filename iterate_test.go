@@ -0,0 +1,175 @@
+package libvirt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestIterateDomains(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	found := false
+	err := env.conn.ForEachDomainSummary(DomListAll, func(dom Domain, summary DomainSummary) error {
+		if summary.Name != env.domData.Name {
+			return nil
+		}
+
+		found = true
+
+		if summary.UUID != env.domData.UUID {
+			t.Errorf("unexpected domain UUID; got=%v, want=%v", summary.UUID, env.domData.UUID)
+		}
+
+		if summary.State != DomStateShutoff {
+			t.Errorf("unexpected domain state; got=%v, want=%v", summary.State, DomStateShutoff)
+		}
+
+		return ErrStopIteration
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !found {
+		t.Errorf("domain not found while iterating; want=%v", env.domData.Name)
+	}
+
+	visitErr := errors.New("visit error")
+	visited := 0
+	err = env.conn.ForEachDomain(DomListAll, func(dom Domain) error {
+		visited++
+		return visitErr
+	})
+	if err != visitErr {
+		t.Errorf("unexpected iteration error; got=%v, want=%v", err, visitErr)
+	}
+
+	if visited != 1 {
+		t.Errorf("iteration did not stop after an error; got=%v visits, want=%v", visited, 1)
+	}
+}
+
+func TestIterateDomainsRemoved(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	doms := make(map[string]Domain)
+	for i := 0; i < 2; i++ {
+		data, err := newTestDomainData(*env.conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer data.cleanUp(*env.conn)
+
+		var xml bytes.Buffer
+		if err = testDomainTmpl.Execute(&xml, data); err != nil {
+			t.Fatal(err)
+		}
+
+		dom, err := env.conn.DefineDomain(xml.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dom.Free()
+
+		doms[data.Name] = dom
+	}
+	defer func() {
+		for _, dom := range doms {
+			if err := dom.Undefine(DomUndefineDefault); err != nil && !IsNotFound(err) {
+				t.Error(err)
+			}
+		}
+	}()
+
+	// the first test domain visited removes the other one, which must be
+	// skipped instead of aborting the iteration
+	visited := 0
+	err := env.conn.ForEachDomainSummary(DomListAll, func(dom Domain, summary DomainSummary) error {
+		if _, ok := doms[summary.Name]; !ok {
+			return nil
+		}
+
+		visited++
+
+		for name, other := range doms {
+			if name != summary.Name {
+				return other.Undefine(DomUndefineDefault)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if visited != 1 {
+		t.Errorf("removed domain was not skipped while iterating; got=%v visits, want=%v", visited, 1)
+	}
+}
+
+func TestIterateStorage(t *testing.T) {
+	env := newTestEnvironment(t).withStorageVolume()
+	defer env.cleanUp()
+
+	found := false
+	err := env.conn.ForEachStoragePoolSummary(PoolListAll, func(pool StoragePool, summary StoragePoolSummary) error {
+		if summary.Name != env.poolData.Name {
+			return nil
+		}
+
+		found = true
+
+		if summary.UUID != env.poolData.UUID {
+			t.Errorf("unexpected storage pool UUID; got=%v, want=%v", summary.UUID, env.poolData.UUID)
+		}
+
+		names := make(map[string]bool)
+		if err := pool.ForEachStorageVolume(func(vol StorageVolume) error {
+			name, err := vol.Name()
+			names[name] = true
+			return err
+		}); err != nil {
+			return err
+		}
+
+		if !names[env.volData.Name] {
+			t.Errorf("storage volume not found while iterating; got=%v, want=%v", names, env.volData.Name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !found {
+		t.Errorf("storage pool not found while iterating; want=%v", env.poolData.Name)
+	}
+}
+
+func TestIterateSecrets(t *testing.T) {
+	env := newTestEnvironment(t).withSecret()
+	defer env.cleanUp()
+
+	found := false
+	err := env.conn.ForEachSecret(SecListAll, func(sec Secret) error {
+		uuid, err := sec.UUID()
+		if err != nil {
+			return err
+		}
+
+		found = found || uuid == env.secData.UUID
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !found {
+		t.Errorf("secret not found while iterating; want=%v", env.secData.UUID)
+	}
+}
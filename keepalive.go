@@ -0,0 +1,127 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+
+int virConnectRegisterCloseCallbackWrapper(virConnectPtr conn, long callbackID);
+int virConnectUnregisterCloseCallbackWrapper(virConnectPtr conn);
+*/
+import "C"
+import (
+	"runtime"
+)
+
+// ConnectionCloseReason describes why a connection has been closed.
+type ConnectionCloseReason int32
+
+// Possible values for ConnectionCloseReason.
+const (
+	ConnCloseReasonError     ConnectionCloseReason = C.VIR_CONNECT_CLOSE_REASON_ERROR
+	ConnCloseReasonEOF       ConnectionCloseReason = C.VIR_CONNECT_CLOSE_REASON_EOF
+	ConnCloseReasonKeepAlive ConnectionCloseReason = C.VIR_CONNECT_CLOSE_REASON_KEEPALIVE
+	ConnCloseReasonClient    ConnectionCloseReason = C.VIR_CONNECT_CLOSE_REASON_CLIENT
+)
+
+// ConnectionCloseCallback is called when a connection is closed. "conn" is the
+// connection which has been closed; it must not be used to issue any libvirt
+// call other than Close.
+type ConnectionCloseCallback func(conn Connection, reason ConnectionCloseReason)
+
+// SetKeepAlive starts sending keepalive messages after "interval" seconds of
+// inactivity and considers the connection to be broken when no response is
+// received after "count" keepalive messages sent in a row. Setting "interval"
+// to zero or a negative number disables keepalive. The connection is then
+// automatically closed and the close callback, if any, is called.
+// Keepalive requires the event loop to be running (see StartEventLoop) and is
+// only supported by remote connections. It returns "false" if the remote party
+// doesn't support keepalive messages.
+func (conn Connection) SetKeepAlive(interval int32, count uint32) (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	conn.log.Printf("setting keepalive (interval = %v, count = %v)...\n", interval, count)
+	cRet := C.virConnectSetKeepAlive(conn.virConnect, C.int(interval), C.uint(count))
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return false, err
+	}
+
+	supported := (ret == 0)
+
+	if supported {
+		conn.log.Println("keepalive set")
+	} else {
+		conn.log.Println("keepalive is not supported by the remote party")
+	}
+
+	return supported, nil
+}
+
+// RegisterCloseCallback registers a callback to be invoked when the connection
+// is closed by something other than a call to Close (e.g. an I/O error, the
+// daemon being restarted or a keepalive timeout). Only one close callback can
+// be registered per connection. The callback requires the event loop to be
+// running (see StartEventLoop).
+func (conn Connection) RegisterCloseCallback(callback ConnectionCloseCallback) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	callbackID := registerCallback(func(reason ConnectionCloseReason) {
+		callback(conn, reason)
+	})
+
+	conn.log.Println("registering close callback...")
+	cRet := C.virConnectRegisterCloseCallbackWrapper(conn.virConnect, C.long(callbackID))
+	ret := int32(cRet)
+
+	if ret == -1 {
+		unregisterCallback(callbackID)
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	conn.log.Println("close callback registered")
+
+	return nil
+}
+
+// UnregisterCloseCallback removes the close callback previously registered
+// with RegisterCloseCallback.
+func (conn Connection) UnregisterCloseCallback() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	conn.log.Println("unregistering close callback...")
+	cRet := C.virConnectUnregisterCloseCallbackWrapper(conn.virConnect)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	conn.log.Println("close callback unregistered")
+
+	return nil
+}
+
+//export connectCloseCallback
+func connectCloseCallback(cConn C.virConnectPtr, cReason C.int, cCallbackID C.long) {
+	callback, ok := lookupCallback(int(cCallbackID)).(func(ConnectionCloseReason))
+	if !ok {
+		return
+	}
+
+	callback(ConnectionCloseReason(cReason))
+}
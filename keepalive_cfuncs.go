@@ -0,0 +1,21 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+
+void connectCloseCallback(virConnectPtr conn, int reason, long callbackID);
+void freeCallbackHelper(void *opaque);
+
+static void connectCloseCallbackHelper(virConnectPtr conn, int reason, void *opaque) {
+    connectCloseCallback(conn, reason, (long)opaque);
+}
+
+int virConnectRegisterCloseCallbackWrapper(virConnectPtr conn, long callbackID) {
+    return virConnectRegisterCloseCallback(conn, connectCloseCallbackHelper, (void *)callbackID, freeCallbackHelper);
+}
+
+int virConnectUnregisterCloseCallbackWrapper(virConnectPtr conn) {
+    return virConnectUnregisterCloseCallback(conn, connectCloseCallbackHelper);
+}
+*/
+import "C"
@@ -0,0 +1,53 @@
+package libvirt
+
+import (
+	"testing"
+)
+
+func TestConnectionKeepAlive(t *testing.T) {
+	if err := StartEventLoop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !EventLoopRunning() {
+		t.Fatal("the event loop should be running after being started")
+	}
+
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	if _, err := env.conn.SetKeepAlive(5, 3); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := env.conn.SetKeepAlive(0, 0); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnectionCloseCallback(t *testing.T) {
+	if err := StartEventLoop(); err != nil {
+		t.Fatal(err)
+	}
+
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	if err := env.conn.UnregisterCloseCallback(); err == nil {
+		t.Error("an error was not returned when unregistering a close callback which was not registered")
+	}
+
+	callback := func(conn Connection, reason ConnectionCloseReason) {}
+
+	if err := env.conn.RegisterCloseCallback(callback); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.conn.RegisterCloseCallback(callback); err == nil {
+		t.Error("an error was not returned when registering a second close callback")
+	}
+
+	if err := env.conn.UnregisterCloseCallback(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,219 @@
+package libvirt
+
+// #include <libvirt/libvirt.h>
+import "C"
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// finalizersEnabled and leakTrackingEnabled hold the settings changed by
+// SetFinalizers and SetLeakTracking (1 means enabled).
+var (
+	finalizersEnabled   int32
+	leakTrackingEnabled int32
+)
+
+// SetFinalizers enables or disables releasing the libvirt objects (domains,
+// storage pools, etc) automatically when they're garbage collected without
+// having been freed. It only affects the objects obtained afterwards. Calling
+// Free explicitly is still recommended, as the garbage collector may take a
+// long time to run.
+func SetFinalizers(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&finalizersEnabled, 1)
+	} else {
+		atomic.StoreInt32(&finalizersEnabled, 0)
+	}
+}
+
+// SetLeakTracking enables or disables tracking the libvirt objects which
+// haven't been freed yet (see LiveObjects). It only affects the objects
+// obtained afterwards. Tracking records the stack trace of every object
+// creation, so it should only be used for debugging.
+func SetLeakTracking(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&leakTrackingEnabled, 1)
+	} else {
+		atomic.StoreInt32(&leakTrackingEnabled, 0)
+	}
+}
+
+// LiveObject describes a libvirt object which hasn't been freed yet.
+type LiveObject struct {
+	Type  string // the object type (e.g. "domain")
+	Refs  int    // the number of references which still must be freed
+	Stack string // the stack trace of the object creation
+}
+
+// liveObjects holds the objects tracked while leak tracking is enabled.
+var liveObjects = struct {
+	sync.Mutex
+	nextID  uint64
+	objects map[uint64]*LiveObject
+}{
+	objects: make(map[uint64]*LiveObject),
+}
+
+// LiveObjects returns the tracked objects which haven't been freed yet, in the
+// order they were created. Objects are only tracked while leak tracking is
+// enabled (see SetLeakTracking), so this can be used by tests to make sure
+// every object is freed.
+func LiveObjects() []LiveObject {
+	liveObjects.Lock()
+	defer liveObjects.Unlock()
+
+	ids := make([]uint64, 0, len(liveObjects.objects))
+	for id := range liveObjects.objects {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	objects := make([]LiveObject, len(ids))
+	for i, id := range ids {
+		objects[i] = *liveObjects.objects[id]
+	}
+
+	return objects
+}
+
+// objectRef tracks the references held by a libvirt object wrapper. It's
+// shared by all the copies of the wrapper, so freeing any of them more times
+// than the object was referenced is a no-op, instead of a crash.
+type objectRef struct {
+	mutex sync.Mutex
+	refs  int
+	free  func()
+	id    uint64 // the ID in "liveObjects", or 0 if it's not tracked
+}
+
+// newObjectRef creates the reference tracker for a new object of type "typ",
+// holding one reference. "free" releases one reference in libvirt, and it's
+// used by the finalizer.
+func newObjectRef(typ string, free func()) *objectRef {
+	ref := &objectRef{
+		refs: 1,
+		free: free,
+	}
+
+	if atomic.LoadInt32(&leakTrackingEnabled) == 1 {
+		buf := make([]byte, 4096)
+		buf = buf[:runtime.Stack(buf, false)]
+
+		liveObjects.Lock()
+		liveObjects.nextID++
+		ref.id = liveObjects.nextID
+		liveObjects.objects[ref.id] = &LiveObject{
+			Type:  typ,
+			Refs:  1,
+			Stack: string(buf),
+		}
+		liveObjects.Unlock()
+	}
+
+	if atomic.LoadInt32(&finalizersEnabled) == 1 {
+		runtime.SetFinalizer(ref, (*objectRef).finalize)
+	}
+
+	return ref
+}
+
+// acquire records a new reference to the object, after it's been referenced
+// in libvirt.
+func (ref *objectRef) acquire() {
+	if ref == nil {
+		return
+	}
+
+	ref.mutex.Lock()
+	defer ref.mutex.Unlock()
+
+	ref.refs++
+	ref.updateTracking()
+}
+
+// release records that one reference to the object is about to be freed in
+// libvirt. It returns false if there are no references left, in which case the
+// object must not be freed again.
+// Objects not created by this package (e.g. the zero value) aren't tracked,
+// so they're always freed.
+func (ref *objectRef) release() bool {
+	if ref == nil {
+		return true
+	}
+
+	ref.mutex.Lock()
+	defer ref.mutex.Unlock()
+
+	if ref.refs == 0 {
+		return false
+	}
+
+	ref.refs--
+	ref.updateTracking()
+
+	if ref.refs == 0 {
+		runtime.SetFinalizer(ref, nil)
+	}
+
+	return true
+}
+
+// finalize frees the references left when the object is garbage collected.
+func (ref *objectRef) finalize() {
+	ref.mutex.Lock()
+	defer ref.mutex.Unlock()
+
+	for ; ref.refs > 0; ref.refs-- {
+		ref.free()
+	}
+
+	ref.updateTracking()
+}
+
+// updateTracking updates the tracked object with the current number of
+// references. It must be called with the mutex locked.
+func (ref *objectRef) updateTracking() {
+	if ref.id == 0 {
+		return
+	}
+
+	liveObjects.Lock()
+	defer liveObjects.Unlock()
+
+	if ref.refs == 0 {
+		delete(liveObjects.objects, ref.id)
+	} else if obj, ok := liveObjects.objects[ref.id]; ok {
+		obj.Refs = ref.refs
+	}
+}
+
+func newDomainRef(ptr C.virDomainPtr) *objectRef {
+	return newObjectRef(objectDomain, func() { C.virDomainFree(ptr) })
+}
+
+func newStoragePoolRef(ptr C.virStoragePoolPtr) *objectRef {
+	return newObjectRef(objectStoragePool, func() { C.virStoragePoolFree(ptr) })
+}
+
+func newStorageVolumeRef(ptr C.virStorageVolPtr) *objectRef {
+	return newObjectRef(objectStorageVolume, func() { C.virStorageVolFree(ptr) })
+}
+
+func newSecretRef(ptr C.virSecretPtr) *objectRef {
+	return newObjectRef(objectSecret, func() { C.virSecretFree(ptr) })
+}
+
+func newSnapshotRef(ptr C.virDomainSnapshotPtr) *objectRef {
+	return newObjectRef(objectSnapshot, func() { C.virDomainSnapshotFree(ptr) })
+}
+
+func newStreamRef(ptr C.virStreamPtr) *objectRef {
+	return newObjectRef(objectStream, func() { C.virStreamFree(ptr) })
+}
+
+func newInterfaceRef(ptr C.virInterfacePtr) *objectRef {
+	return newObjectRef(objectInterface, func() { C.virInterfaceFree(ptr) })
+}
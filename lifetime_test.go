@@ -0,0 +1,103 @@
+package libvirt
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func countLiveObjects(typ string) (count int, refs int) {
+	for _, obj := range LiveObjects() {
+		if obj.Type == typ {
+			count++
+			refs += obj.Refs
+		}
+	}
+
+	return count, refs
+}
+
+func TestLifetimeFree(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	initialCount, _ := countLiveObjects(objectDomain)
+
+	dom, err := env.conn.LookupDomainByName(env.domData.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count, refs := countLiveObjects(objectDomain); count != initialCount+1 || refs != initialCount+1 {
+		t.Errorf("unexpected live domains after lookup; got=%v (%v refs), want=%v (%v refs)", count, refs, initialCount+1, initialCount+1)
+	}
+
+	if err = dom.Ref(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, refs := countLiveObjects(objectDomain); refs != initialCount+2 {
+		t.Errorf("unexpected live domain references after Ref; got=%v, want=%v", refs, initialCount+2)
+	}
+
+	domCopy := dom
+
+	if err = dom.Free(); err != nil {
+		t.Error(err)
+	}
+
+	if err = domCopy.Free(); err != nil {
+		t.Error(err)
+	}
+
+	if count, _ := countLiveObjects(objectDomain); count != initialCount {
+		t.Errorf("unexpected live domains after Free; got=%v, want=%v", count, initialCount)
+	}
+
+	// freeing more times than referenced must be a no-op
+	if err = dom.Free(); err != nil {
+		t.Errorf("freeing a domain twice returned an error: %v", err)
+	}
+
+	if err = domCopy.Free(); err != nil {
+		t.Errorf("freeing a domain copy twice returned an error: %v", err)
+	}
+}
+
+func TestLifetimeFinalizer(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	SetFinalizers(true)
+	defer SetFinalizers(false)
+
+	initialCount, _ := countLiveObjects(objectDomain)
+
+	func() {
+		if _, err := env.conn.LookupDomainByName(env.domData.Name); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		runtime.GC()
+
+		count, _ := countLiveObjects(objectDomain)
+		if count == initialCount {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("the domain was not released by the finalizer; got=%v live domains, want=%v: %+v", count, initialCount, LiveObjects())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
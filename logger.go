@@ -0,0 +1,306 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+#include <libvirt/virterror.h>
+
+void emptyErrorFunc(void *userData, virErrorPtr error);
+void libvirtErrorFuncHelper(void *userData, virErrorPtr error);
+*/
+import "C"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Logger receives the log messages of a libvirt connection and all the objects
+// obtained from it. Each message comes with a set of key-value fields (see
+// Field); implementations may format them as they wish.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// Field is a key-value pair attached to a log message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Keys of the fields attached to the log messages by this package.
+const (
+	FieldObject    = "object"
+	FieldName      = "name"
+	FieldUUID      = "uuid"
+	FieldOperation = "op"
+	FieldDuration  = "duration"
+	FieldFlags     = "flags"
+	FieldError     = "error"
+	FieldCode      = "code"
+	FieldDomain    = "domain"
+)
+
+// Object types used as the FieldObject value.
+const (
+	objectConnection    = "connection"
+	objectDomain        = "domain"
+	objectInterface     = "interface"
+	objectSecret        = "secret"
+	objectSnapshot      = "snapshot"
+	objectStoragePool   = "storage pool"
+	objectStorageVolume = "storage volume"
+	objectStream        = "stream"
+)
+
+// DebugEnabler can be implemented by a Logger to tell whether it keeps the
+// debug messages. If it doesn't, the debug messages which are expensive to
+// build (e.g. the one logged after every libvirt call) are skipped.
+type DebugEnabler interface {
+	DebugEnabled() bool
+}
+
+// writerLogger is a Logger which writes plain text lines to an io.Writer.
+type writerLogger struct {
+	log     *log.Logger
+	discard bool
+}
+
+// NewWriterLogger creates a Logger which writes one line per message to
+// "output", in the format "<level> <message> key=value...". This is the logger
+// used by the functions which receive an io.Writer as the log output, such as
+// Open.
+func NewWriterLogger(output io.Writer) Logger {
+	return writerLogger{
+		log:     log.New(output, "libvirt-golang: ", log.LstdFlags),
+		discard: output == ioutil.Discard,
+	}
+}
+
+func (l writerLogger) write(level string, msg string, fields []Field) {
+	var buf bytes.Buffer
+
+	buf.WriteString(level)
+	buf.WriteByte(' ')
+	buf.WriteString(msg)
+
+	for _, f := range fields {
+		fmt.Fprintf(&buf, " %v=%v", f.Key, f.Value)
+	}
+
+	l.log.Println(buf.String())
+}
+
+// Debug implements Logger.
+func (l writerLogger) Debug(msg string, fields ...Field) {
+	l.write("DEBUG", msg, fields)
+}
+
+// Info implements Logger.
+func (l writerLogger) Info(msg string, fields ...Field) {
+	l.write("INFO", msg, fields)
+}
+
+// Warn implements Logger.
+func (l writerLogger) Warn(msg string, fields ...Field) {
+	l.write("WARN", msg, fields)
+}
+
+// Error implements Logger.
+func (l writerLogger) Error(msg string, fields ...Field) {
+	l.write("ERROR", msg, fields)
+}
+
+// DebugEnabled implements DebugEnabler. It returns false if the output is
+// ioutil.Discard.
+func (l writerLogger) DebugEnabled() bool {
+	return !l.discard
+}
+
+// loggerHolder allows storing any Logger in an atomic.Value, which requires
+// the same concrete type on every store.
+type loggerHolder struct {
+	Logger
+}
+
+// logger is the internal logger shared by a connection and its objects. The
+// output Logger and the instrumentation hook are shared by all of them, so
+// they can be replaced later (see Connection.SetLogger and
+// Connection.SetInstrumentationHook); the fields are specific to each object.
+type logger struct {
+	shared *loggerShared
+	fields []Field
+}
+
+// loggerShared holds the state shared by all the loggers of a connection.
+type loggerShared struct {
+	out  atomic.Value // loggerHolder
+	hook atomic.Value // InstrumentationHook
+}
+
+// newLogger creates a logger object to be used across a libvirt
+// connection. It prints the messages to "output".
+func newLogger(output io.Writer) *logger {
+	return newLoggerFrom(NewWriterLogger(output))
+}
+
+// newLoggerFrom creates a logger object to be used across a libvirt
+// connection, which sends the messages to "out".
+func newLoggerFrom(out Logger) *logger {
+	l := &logger{
+		shared: new(loggerShared),
+		fields: []Field{{FieldObject, objectConnection}},
+	}
+	l.shared.out.Store(loggerHolder{out})
+
+	return l
+}
+
+// with returns a logger which shares the output with "l" and attaches
+// "fields" to every message, in addition to the fields of "l". Fields with the
+// same key are replaced. If "fields" sets the object type (i.e. the logger is
+// for another object), the name and the UUID of the object of "l" are dropped
+// as well.
+func (l *logger) with(fields ...Field) *logger {
+	newFields := make([]Field, 0, len(l.fields)+len(fields))
+
+	newObject := false
+	for _, nf := range fields {
+		if nf.Key == FieldObject {
+			newObject = true
+			break
+		}
+	}
+
+	for _, f := range l.fields {
+		replaced := newObject && (f.Key == FieldName || f.Key == FieldUUID)
+		for _, nf := range fields {
+			if f.Key == nf.Key {
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			newFields = append(newFields, f)
+		}
+	}
+
+	return &logger{
+		shared: l.shared,
+		fields: append(newFields, fields...),
+	}
+}
+
+func (l *logger) output() Logger {
+	return l.shared.out.Load().(loggerHolder).Logger
+}
+
+func (l *logger) setOutput(out Logger) {
+	l.shared.out.Store(loggerHolder{out})
+}
+
+// debugEnabled reports whether the output keeps the debug messages (see
+// DebugEnabler).
+func (l *logger) debugEnabled() bool {
+	if d, ok := l.output().(DebugEnabler); ok {
+		return d.DebugEnabled()
+	}
+
+	return true
+}
+
+// Printf logs a debug message, formatted like fmt.Printf.
+func (l *logger) Printf(format string, args ...interface{}) {
+	l.output().Debug(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"), l.fields...)
+}
+
+// Println logs a debug message, formatted like fmt.Println.
+func (l *logger) Println(args ...interface{}) {
+	l.output().Debug(strings.TrimSuffix(fmt.Sprintln(args...), "\n"), l.fields...)
+}
+
+// Warnf logs a warning message, formatted like fmt.Printf.
+func (l *logger) Warnf(format string, args ...interface{}) {
+	l.output().Warn(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"), l.fields...)
+}
+
+// error logs "err" as an error message, along with the operation which failed.
+func (l *logger) error(err error) {
+	l.errorFrom(err, 3)
+}
+
+// errorFrom works like error, but the operation which failed is the function
+// "skip" frames above (see callerName) if "err" doesn't tell it.
+func (l *logger) errorFrom(err error, skip int) {
+	fields := append(l.fields[:len(l.fields):len(l.fields)], Field{FieldError, err})
+
+	if virErr, ok := err.(*Error); ok && virErr != nil {
+		if virErr.Op != "" {
+			fields = append(fields, Field{FieldOperation, virErr.Op})
+		}
+		fields = append(fields, Field{FieldCode, virErr.Code})
+	} else if op := callerName(skip); op != "" {
+		fields = append(fields, Field{FieldOperation, op})
+	}
+
+	l.output().Error("an error occurred", fields...)
+}
+
+// SetLogger replaces the logger of the connection, and of all the objects
+// obtained from it, with "out".
+func (conn Connection) SetLogger(out Logger) {
+	conn.log.setOutput(out)
+}
+
+// libvirtLogger holds the Logger which receives the errors reported by libvirt
+// itself (see SetLibvirtLogger).
+var libvirtLogger atomic.Value
+
+// SetLibvirtLogger routes the errors reported by libvirt itself, which are
+// otherwise discarded, to "out". Warnings are logged with Warn and errors with
+// Error. If "out" is nil, they're discarded again. This is a global setting.
+// libvirt's own debug logs can only be configured with the environment
+// variables LIBVIRT_LOG_FILTERS and LIBVIRT_LOG_OUTPUTS, which must be set
+// before the program starts.
+func SetLibvirtLogger(out Logger) {
+	if out == nil {
+		C.virSetErrorFunc(nil, C.virErrorFunc(unsafe.Pointer(C.emptyErrorFunc)))
+		libvirtLogger.Store(loggerHolder{})
+		return
+	}
+
+	libvirtLogger.Store(loggerHolder{out})
+	C.virSetErrorFunc(nil, C.virErrorFunc(unsafe.Pointer(C.libvirtErrorFuncHelper)))
+}
+
+//export libvirtErrorCallback
+func libvirtErrorCallback(virError C.virErrorPtr) {
+	holder, ok := libvirtLogger.Load().(loggerHolder)
+	if !ok || holder.Logger == nil {
+		return
+	}
+
+	err := NewError(virError)
+	if err == nil {
+		return
+	}
+
+	fields := []Field{
+		{FieldCode, err.Code},
+		{FieldDomain, err.Domain},
+	}
+
+	if err.Level == ErrLvlWarning {
+		holder.Warn(err.Message, fields...)
+	} else {
+		holder.Error(err.Message, fields...)
+	}
+}
@@ -0,0 +1,12 @@
+package libvirt
+
+/*
+#include <libvirt/virterror.h>
+
+void libvirtErrorCallback(virErrorPtr error);
+
+void libvirtErrorFuncHelper(void *userData, virErrorPtr error) {
+    libvirtErrorCallback(error);
+}
+*/
+import "C"
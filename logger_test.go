@@ -0,0 +1,156 @@
+package libvirt
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cd1/utils-golang"
+)
+
+type testLogEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+type testLogger struct {
+	mutex   sync.Mutex
+	entries []testLogEntry
+}
+
+func (l *testLogger) log(level string, msg string, fields []Field) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entry := testLogEntry{level, msg, make(map[string]interface{})}
+	for _, f := range fields {
+		entry.fields[f.Key] = f.Value
+	}
+
+	l.entries = append(l.entries, entry)
+}
+
+func (l *testLogger) Debug(msg string, fields ...Field) { l.log("debug", msg, fields) }
+func (l *testLogger) Info(msg string, fields ...Field)  { l.log("info", msg, fields) }
+func (l *testLogger) Warn(msg string, fields ...Field)  { l.log("warn", msg, fields) }
+func (l *testLogger) Error(msg string, fields ...Field) { l.log("error", msg, fields) }
+
+func (l *testLogger) find(level string, field string, value interface{}) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, e := range l.entries {
+		if e.level == level && e.fields[field] == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestLoggerWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := NewWriterLogger(&buf)
+	logger.Info("hello", Field{FieldName, "world"}, Field{FieldFlags, 2})
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "libvirt-golang: ") {
+		t.Errorf("unexpected log prefix; got=%q, want prefix=%q", line, "libvirt-golang: ")
+	}
+
+	if want := "INFO hello name=world flags=2\n"; !strings.HasSuffix(line, want) {
+		t.Errorf("unexpected log line; got=%q, want suffix=%q", line, want)
+	}
+}
+
+func TestLoggerConnection(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	logger := new(testLogger)
+	env.conn.SetLogger(logger)
+
+	if _, err := env.conn.LookupDomainByName(utils.RandomString()); err == nil {
+		t.Fatal("an error was not returned when looking up a non-existing domain")
+	}
+
+	if !logger.find("error", FieldOperation, "Connection.LookupDomainByName") {
+		t.Errorf("the failed operation was not logged as an error: %v", logger.entries)
+	}
+
+	// the domain was created before setting the logger, but it must use the new one
+	if _, err := env.dom.Name(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !logger.find("debug", FieldObject, objectDomain) {
+		t.Errorf("the domain operation was not logged: %v", logger.entries)
+	}
+}
+
+func TestLoggerFields(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	logger := new(testLogger)
+	env.conn.SetLogger(logger)
+
+	if _, err := env.dom.XML(DomXMLInactive); err != nil {
+		t.Fatal(err)
+	}
+
+	if !logger.find("debug", FieldFlags, DomXMLInactive) {
+		t.Errorf("the flags were not logged as a field: %v", logger.entries)
+	}
+}
+
+func TestLoggerWith(t *testing.T) {
+	out := new(testLogger)
+	poolLog := newLoggerFrom(out).with(Field{FieldObject, objectStoragePool}, Field{FieldName, "pool"}, Field{FieldUUID, "uuid"})
+
+	hasField := func(l *logger, key string) bool {
+		for _, f := range l.fields {
+			if f.Key == key {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if flagsLog := poolLog.with(Field{FieldFlags, 1}); !hasField(flagsLog, FieldName) || !hasField(flagsLog, FieldUUID) {
+		t.Errorf("the object identity was dropped when adding another field: %v", flagsLog.fields)
+	}
+
+	volLog := poolLog.with(Field{FieldObject, objectStorageVolume})
+	if hasField(volLog, FieldName) || hasField(volLog, FieldUUID) {
+		t.Errorf("the identity of the parent object was kept by a child object: %v", volLog.fields)
+	}
+
+	// a nil libvirt error must not crash the logger
+	volLog.error((*Error)(nil))
+
+	if !out.find("error", FieldObject, objectStorageVolume) {
+		t.Errorf("the nil error was not logged: %v", out.entries)
+	}
+}
+
+func TestLoggerLibvirt(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	logger := new(testLogger)
+	SetLibvirtLogger(logger)
+	defer SetLibvirtLogger(nil)
+
+	if _, err := env.conn.LookupDomainByName(utils.RandomString()); err == nil {
+		t.Fatal("an error was not returned when looking up a non-existing domain")
+	}
+
+	if !logger.find("error", FieldCode, ErrNoDomain) {
+		t.Errorf("the libvirt error was not logged: %v", logger.entries)
+	}
+}
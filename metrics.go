@@ -0,0 +1,155 @@
+package libvirt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cd1/libvirt-golang/internal/promtext"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets used by Metrics when no other value is specified.
+var DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics collects call counters and latency histograms from the
+// instrumentation hook of one or more connections, and exposes them in the
+// Prometheus text format. Its Hook method must be set as the instrumentation
+// hook (see Connection.SetInstrumentationHook). It's safe for concurrent use.
+type Metrics struct {
+	buckets []float64
+
+	mutex     sync.Mutex
+	calls     map[callKey]uint64
+	latencies map[string]*latencyHistogram
+}
+
+// callKey identifies a counter of Metrics.
+type callKey struct {
+	operation string
+	object    string
+	code      ErrorCode
+}
+
+// latencyHistogram is a latency histogram of Metrics.
+type latencyHistogram struct {
+	counts []uint64 // one per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewMetrics creates an empty Metrics object which uses "buckets" as the upper
+// bounds, in seconds, of the latency histogram buckets. If "buckets" is empty,
+// DefaultLatencyBuckets is used.
+func NewMetrics(buckets []float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	sortedBuckets := make([]float64, len(buckets))
+	copy(sortedBuckets, buckets)
+	sort.Float64s(sortedBuckets)
+
+	return &Metrics{
+		buckets:   sortedBuckets,
+		calls:     make(map[callKey]uint64),
+		latencies: make(map[string]*latencyHistogram),
+	}
+}
+
+// Hook records a libvirt call. It has the signature of an
+// InstrumentationHook.
+func (m *Metrics) Hook(info CallInfo) {
+	seconds := info.Duration.Seconds()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.calls[callKey{info.Operation, info.Object, info.Code}]++
+
+	hist, ok := m.latencies[info.Operation]
+	if !ok {
+		hist = &latencyHistogram{
+			counts: make([]uint64, len(m.buckets)),
+		}
+		m.latencies[info.Operation] = hist
+	}
+
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			hist.counts[i]++
+			break
+		}
+	}
+
+	hist.count++
+	hist.sum += seconds
+}
+
+// WriteTo writes the current metrics to "w" in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cw := &promtext.CountingWriter{W: w}
+	bw := bufio.NewWriter(cw)
+
+	callKeys := make([]callKey, 0, len(m.calls))
+	for k := range m.calls {
+		callKeys = append(callKeys, k)
+	}
+	sort.Slice(callKeys, func(i, j int) bool {
+		if callKeys[i].operation != callKeys[j].operation {
+			return callKeys[i].operation < callKeys[j].operation
+		}
+		if callKeys[i].object != callKeys[j].object {
+			return callKeys[i].object < callKeys[j].object
+		}
+		return callKeys[i].code < callKeys[j].code
+	})
+
+	fmt.Fprintln(bw, "# HELP libvirt_calls_total Number of libvirt calls, by operation and error code.")
+	fmt.Fprintln(bw, "# TYPE libvirt_calls_total counter")
+	for _, k := range callKeys {
+		fmt.Fprintf(bw, "libvirt_calls_total{operation=%v,object=%v,code=%v} %v\n",
+			promtext.QuoteLabel(k.operation), promtext.QuoteLabel(k.object), promtext.QuoteLabel(k.code.String()), m.calls[k])
+	}
+
+	operations := make([]string, 0, len(m.latencies))
+	for op := range m.latencies {
+		operations = append(operations, op)
+	}
+	sort.Strings(operations)
+
+	fmt.Fprintln(bw, "# HELP libvirt_call_duration_seconds Duration of libvirt calls, by operation.")
+	fmt.Fprintln(bw, "# TYPE libvirt_call_duration_seconds histogram")
+	for _, op := range operations {
+		hist := m.latencies[op]
+		label := promtext.QuoteLabel(op)
+
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(bw, "libvirt_call_duration_seconds_bucket{operation=%v,le=\"%v\"} %v\n",
+				label, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(bw, "libvirt_call_duration_seconds_bucket{operation=%v,le=\"+Inf\"} %v\n", label, hist.count)
+		fmt.Fprintf(bw, "libvirt_call_duration_seconds_sum{operation=%v} %v\n", label, strconv.FormatFloat(hist.sum, 'g', -1, 64))
+		fmt.Fprintf(bw, "libvirt_call_duration_seconds_count{operation=%v} %v\n", label, hist.count)
+	}
+
+	err := bw.Flush()
+
+	return cw.N, err
+}
+
+// ServeHTTP writes the current metrics as an HTTP response, so Metrics can be
+// used as the handler of a Prometheus scrape endpoint.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
@@ -0,0 +1,162 @@
+package libvirt
+
+// ObjectKind is the type of a libvirt object.
+type ObjectKind string
+
+// Possible values for ObjectKind.
+const (
+	KindDomain        ObjectKind = objectDomain
+	KindInterface     ObjectKind = objectInterface
+	KindSecret        ObjectKind = objectSecret
+	KindSnapshot      ObjectKind = objectSnapshot
+	KindStoragePool   ObjectKind = objectStoragePool
+	KindStorageVolume ObjectKind = objectStorageVolume
+)
+
+// Referenced is implemented by every libvirt object which is reference
+// counted, including streams.
+type Referenced interface {
+	Free() error
+	Ref() error
+}
+
+// Object is implemented by every libvirt object which is described by XML:
+// Domain, Interface, Secret, Snapshot, StoragePool and StorageVolume. Streams
+// only implement Referenced.
+type Object interface {
+	Referenced
+
+	// Kind returns the type of the object.
+	Kind() ObjectKind
+
+	// Identifier returns a string which identifies the object among the
+	// objects of the same kind: the UUID of domains, secrets and storage
+	// pools, the key of storage volumes and the name of snapshots (within
+	// their domain) and interfaces.
+	Identifier() (string, error)
+
+	// XMLDesc returns the XML description of the object, with the default
+	// flags. The XML methods of each type provide more options.
+	XMLDesc() (string, error)
+}
+
+// Lifecycle is implemented by the objects which can be started, stopped and
+// undefined: StoragePool, and Domain through its Lifecycle method.
+type Lifecycle interface {
+	Create() error
+	Destroy() error
+	Undefine() error
+}
+
+// Kind returns KindDomain.
+func (dom Domain) Kind() ObjectKind {
+	return KindDomain
+}
+
+// Identifier returns the domain UUID.
+func (dom Domain) Identifier() (string, error) {
+	return dom.UUID()
+}
+
+// XMLDesc returns the domain XML, with DomXMLDefault.
+func (dom Domain) XMLDesc() (string, error) {
+	return dom.XML(DomXMLDefault)
+}
+
+// domainLifecycle implements Lifecycle for a domain, using the default flags.
+type domainLifecycle struct {
+	dom Domain
+}
+
+func (l domainLifecycle) Create() error {
+	return l.dom.Create(DomCreateDefault)
+}
+
+func (l domainLifecycle) Destroy() error {
+	return l.dom.Destroy(DomDestroyDefault)
+}
+
+func (l domainLifecycle) Undefine() error {
+	return l.dom.Undefine(DomUndefineDefault)
+}
+
+// Lifecycle returns the domain as a Lifecycle object, which starts, destroys
+// and undefines it with the default flags.
+func (dom Domain) Lifecycle() Lifecycle {
+	return domainLifecycle{dom}
+}
+
+// Kind returns KindInterface.
+func (iface Interface) Kind() ObjectKind {
+	return KindInterface
+}
+
+// Identifier returns the interface name.
+func (iface Interface) Identifier() (string, error) {
+	return iface.Name()
+}
+
+// XMLDesc returns the interface XML, with IfaceXMLDefault.
+func (iface Interface) XMLDesc() (string, error) {
+	return iface.XML(IfaceXMLDefault)
+}
+
+// Kind returns KindSecret.
+func (sec Secret) Kind() ObjectKind {
+	return KindSecret
+}
+
+// Identifier returns the secret UUID.
+func (sec Secret) Identifier() (string, error) {
+	return sec.UUID()
+}
+
+// XMLDesc returns the secret XML.
+func (sec Secret) XMLDesc() (string, error) {
+	return sec.XML()
+}
+
+// Kind returns KindSnapshot.
+func (snap Snapshot) Kind() ObjectKind {
+	return KindSnapshot
+}
+
+// Identifier returns the snapshot name.
+func (snap Snapshot) Identifier() (string, error) {
+	return snap.Name()
+}
+
+// XMLDesc returns the snapshot XML, with DomXMLDefault.
+func (snap Snapshot) XMLDesc() (string, error) {
+	return snap.XML(DomXMLDefault)
+}
+
+// Kind returns KindStoragePool.
+func (pool StoragePool) Kind() ObjectKind {
+	return KindStoragePool
+}
+
+// Identifier returns the storage pool UUID.
+func (pool StoragePool) Identifier() (string, error) {
+	return pool.UUID()
+}
+
+// XMLDesc returns the storage pool XML, with StorageXMLDefault.
+func (pool StoragePool) XMLDesc() (string, error) {
+	return pool.XML(StorageXMLDefault)
+}
+
+// Kind returns KindStorageVolume.
+func (vol StorageVolume) Kind() ObjectKind {
+	return KindStorageVolume
+}
+
+// Identifier returns the storage volume key.
+func (vol StorageVolume) Identifier() (string, error) {
+	return vol.Key()
+}
+
+// XMLDesc returns the storage volume XML.
+func (vol StorageVolume) XMLDesc() (string, error) {
+	return vol.XML()
+}
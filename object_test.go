@@ -0,0 +1,108 @@
+package libvirt
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	_ Object     = Domain{}
+	_ Object     = Interface{}
+	_ Object     = Secret{}
+	_ Object     = Snapshot{}
+	_ Object     = StoragePool{}
+	_ Object     = StorageVolume{}
+	_ Referenced = Stream{}
+	_ Lifecycle  = StoragePool{}
+	_ Lifecycle  = Domain{}.Lifecycle()
+)
+
+func testObject(t *testing.T, obj Object, kind ObjectKind, identifier string) {
+	if k := obj.Kind(); k != kind {
+		t.Errorf("unexpected object kind; got=%v, want=%v", k, kind)
+	}
+
+	id, err := obj.Identifier()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if id != identifier {
+		t.Errorf("unexpected %v identifier; got=%v, want=%v", kind, id, identifier)
+	}
+
+	xml, err := obj.XMLDesc()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !strings.Contains(xml, identifier) {
+		t.Errorf("%v XML does not contain its identifier; got=%q, want=%q", kind, xml, identifier)
+	}
+}
+
+func TestObjectConformance(t *testing.T) {
+	env := newTestEnvironment(t).withSnapshot().withSecret().withStorageVolume()
+	defer env.cleanUp()
+
+	key, err := env.vol.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testObject(t, *env.dom, KindDomain, env.domData.UUID)
+	testObject(t, *env.snap, KindSnapshot, env.snapData.Name)
+	testObject(t, *env.sec, KindSecret, env.secData.UUID)
+	testObject(t, *env.pool, KindStoragePool, env.poolData.UUID)
+	testObject(t, *env.vol, KindStorageVolume, key)
+}
+
+func TestObjectLifecycle(t *testing.T) {
+	env := newTestEnvironment(t).withDomain()
+	defer env.cleanUp()
+
+	lifecycle := env.dom.Lifecycle()
+
+	if err := lifecycle.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if active, err := env.dom.IsActive(); err != nil || !active {
+		t.Errorf("domain is not active after Lifecycle.Create; got=%v (err=%v), want=%v", active, err, true)
+	}
+
+	if err := lifecycle.Destroy(); err != nil {
+		t.Error(err)
+	}
+
+	if active, err := env.dom.IsActive(); err != nil || active {
+		t.Errorf("domain is still active after Lifecycle.Destroy; got=%v (err=%v), want=%v", active, err, false)
+	}
+}
+
+func TestObjectInterfaces(t *testing.T) {
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	err := env.conn.ForEachInterface(IfaceListAll, func(iface Interface) error {
+		name, err := iface.Name()
+		if err != nil {
+			return err
+		}
+
+		testObject(t, iface, KindInterface, name)
+
+		if _, err = iface.MACString(); err != nil {
+			return err
+		}
+
+		if err = iface.Ref(); err != nil {
+			return err
+		}
+
+		return iface.Free()
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
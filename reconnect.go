@@ -0,0 +1,247 @@
+package libvirt
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultReconnectInterval is the time waited between two attempts of
+// reopening a connection, if no other value is specified.
+const DefaultReconnectInterval = 5 * time.Second
+
+// ErrReconnectingConnectionClosed is returned by a ReconnectingConnection
+// after it has been closed.
+var ErrReconnectingConnectionClosed = errors.New("reconnecting libvirt connection is closed")
+
+// ReconnectOptions controls how a ReconnectingConnection keeps its connection
+// alive.
+type ReconnectOptions struct {
+	// KeepAliveInterval and KeepAliveCount are passed to SetKeepAlive every
+	// time the connection is opened. A zero KeepAliveInterval keeps
+	// libvirt's default.
+	KeepAliveInterval int32
+	KeepAliveCount    uint32
+
+	// RetryInterval is the time waited between two attempts of reopening
+	// the connection. A zero value means DefaultReconnectInterval.
+	RetryInterval time.Duration
+
+	// Logger, if not nil, is set as the logger of every connection opened
+	// (see Connection.SetLogger), instead of writing to the log output.
+	Logger Logger
+}
+
+// ReconnectingConnection holds a libvirt connection which is automatically
+// reopened, with the same URI and mode, whenever it is closed by something
+// other than a call to Close (e.g. the daemon being restarted). Subscribers
+// are notified every time a new connection is established, so they can, for
+// example, register their events again.
+// The event loop must be running (see StartEventLoop) for the connection to
+// be reopened.
+type ReconnectingConnection struct {
+	uri       string
+	mode      ConnectionMode
+	logOutput io.Writer
+	opts      ReconnectOptions
+
+	mutex       sync.Mutex
+	conn        Connection
+	connected   bool
+	closed      bool
+	subscribers map[int]func(Connection)
+	nextSubID   int
+}
+
+// NewReconnectingConnection opens a new libvirt connection which will be
+// reopened automatically when it's closed unexpectedly. The first connection
+// attempt must succeed, otherwise an error is returned.
+func NewReconnectingConnection(uri string, mode ConnectionMode, logOutput io.Writer, opts ReconnectOptions) (*ReconnectingConnection, error) {
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultReconnectInterval
+	}
+
+	rc := &ReconnectingConnection{
+		uri:         uri,
+		mode:        mode,
+		logOutput:   logOutput,
+		opts:        opts,
+		subscribers: make(map[int]func(Connection)),
+	}
+
+	conn, err := rc.open()
+	if err != nil {
+		return nil, err
+	}
+
+	rc.conn = conn
+	rc.connected = true
+
+	return rc, nil
+}
+
+// open opens a new connection and sets it up. It may block for a long time
+// (e.g. while the daemon is down), so it must not be called with the mutex
+// locked.
+func (rc *ReconnectingConnection) open() (Connection, error) {
+	conn, err := Open(rc.uri, rc.mode, rc.logOutput)
+	if err != nil {
+		return Connection{}, err
+	}
+
+	if rc.opts.Logger != nil {
+		conn.SetLogger(rc.opts.Logger)
+	}
+
+	if rc.opts.KeepAliveInterval != 0 {
+		if _, err = conn.SetKeepAlive(rc.opts.KeepAliveInterval, rc.opts.KeepAliveCount); err != nil {
+			conn.Close()
+			return Connection{}, err
+		}
+	}
+
+	if err = conn.RegisterCloseCallback(rc.onClose); err != nil {
+		conn.Close()
+		return Connection{}, err
+	}
+
+	return conn, nil
+}
+
+// onClose is called by libvirt when the current connection is closed.
+func (rc *ReconnectingConnection) onClose(conn Connection, reason ConnectionCloseReason) {
+	conn.log.Warnf("connection closed unexpectedly (reason = %v); reconnecting...\n", reason)
+
+	// libvirt must not be called from inside the close callback.
+	go rc.reconnect(conn)
+}
+
+// reconnect releases the connection which has been closed and keeps trying to
+// open a new one until it succeeds or Close is called.
+func (rc *ReconnectingConnection) reconnect(old Connection) {
+	rc.mutex.Lock()
+	if rc.closed || rc.conn.virConnect != old.virConnect {
+		rc.mutex.Unlock()
+		return
+	}
+	rc.connected = false
+	rc.mutex.Unlock()
+
+	old.Close()
+
+	for {
+		rc.mutex.Lock()
+		closed := rc.closed
+		rc.mutex.Unlock()
+
+		if closed {
+			return
+		}
+
+		conn, err := rc.open()
+		if err != nil {
+			time.Sleep(rc.opts.RetryInterval)
+			continue
+		}
+
+		rc.mutex.Lock()
+		if rc.closed {
+			// Close was called while the connection was being opened.
+			rc.mutex.Unlock()
+
+			conn.UnregisterCloseCallback()
+			conn.Close()
+
+			return
+		}
+
+		rc.conn = conn
+		rc.connected = true
+
+		subscribers := make([]func(Connection), 0, len(rc.subscribers))
+		for _, s := range rc.subscribers {
+			subscribers = append(subscribers, s)
+		}
+		rc.mutex.Unlock()
+
+		conn.log.Println("connection reestablished")
+
+		for _, s := range subscribers {
+			s(conn)
+		}
+
+		return
+	}
+}
+
+// Connection returns the current libvirt connection. It returns an error if
+// the connection is being reestablished or if the ReconnectingConnection has
+// been closed. The returned connection must not be closed by the caller.
+func (rc *ReconnectingConnection) Connection() (Connection, error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if rc.closed {
+		return Connection{}, ErrReconnectingConnectionClosed
+	}
+
+	if !rc.connected {
+		return Connection{}, &Error{
+			Code:    ErrNoConnect,
+			Domain:  ErrDomNone,
+			Message: "libvirt connection is being reestablished",
+			Level:   ErrLvlError,
+		}
+	}
+
+	return rc.conn, nil
+}
+
+// Subscribe registers a function which is called with the new connection every
+// time it's reestablished. It returns an ID which can be used to unsubscribe.
+func (rc *ReconnectingConnection) Subscribe(fn func(Connection)) int {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	rc.nextSubID++
+	rc.subscribers[rc.nextSubID] = fn
+
+	return rc.nextSubID
+}
+
+// Unsubscribe removes a function previously registered with Subscribe.
+func (rc *ReconnectingConnection) Unsubscribe(id int) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	delete(rc.subscribers, id)
+}
+
+// Close closes the current connection and stops reconnecting. The connection
+// is closed even if its close callback can't be unregistered; the first error
+// is returned.
+func (rc *ReconnectingConnection) Close() error {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if rc.closed {
+		return ErrReconnectingConnectionClosed
+	}
+
+	rc.closed = true
+
+	if !rc.connected {
+		return nil
+	}
+
+	rc.connected = false
+
+	err := rc.conn.UnregisterCloseCallback()
+
+	if _, closeErr := rc.conn.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,50 @@
+package libvirt
+
+import (
+	"testing"
+)
+
+func TestReconnectingConnection(t *testing.T) {
+	if err := StartEventLoop(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ReconnectOptions{
+		KeepAliveInterval: 5,
+		KeepAliveCount:    3,
+	}
+
+	rc, err := NewReconnectingConnection(testConnectionURI, ReadWrite, testLogOutput, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := rc.Subscribe(func(conn Connection) {})
+	rc.Unsubscribe(id)
+
+	conn, err := rc.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alive, err := conn.IsAlive()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !alive {
+		t.Error("the reconnecting libvirt connection was opened but it is not alive")
+	}
+
+	if err = rc.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = rc.Connection(); err != ErrReconnectingConnectionClosed {
+		t.Errorf("unexpected error when using a closed reconnecting connection; got=%v, want=%v", err, ErrReconnectingConnectionClosed)
+	}
+
+	if err = rc.Close(); err != ErrReconnectingConnectionClosed {
+		t.Errorf("unexpected error when closing a reconnecting connection twice; got=%v, want=%v", err, ErrReconnectingConnectionClosed)
+	}
+}
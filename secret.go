@@ -4,7 +4,8 @@ package libvirt
 // #include <libvirt/libvirt.h>
 import "C"
 import (
-	"log"
+	"encoding/xml"
+	"runtime"
 	"unicode/utf8"
 	"unsafe"
 )
@@ -30,23 +31,59 @@ const (
 	SecUsageTypeVolume SecretUsageType = C.VIR_SECRET_USAGE_TYPE_VOLUME
 	SecUsageTypeCeph   SecretUsageType = C.VIR_SECRET_USAGE_TYPE_CEPH
 	SecUsageTypeISCSI  SecretUsageType = C.VIR_SECRET_USAGE_TYPE_ISCSI
+	SecUsageTypeTLS    SecretUsageType = C.VIR_SECRET_USAGE_TYPE_TLS
+	SecUsageTypeVTPM   SecretUsageType = C.VIR_SECRET_USAGE_TYPE_VTPM
 )
 
+// SecretUsage describes what a secret is used for, as defined in the "usage"
+// element of its XML. Only the field which matches "Type" is set.
+type SecretUsage struct {
+	Type   SecretUsageType
+	Volume string // the path of the volume (SecUsageTypeVolume)
+	Name   string // the usage name (SecUsageTypeCeph, SecUsageTypeTLS and SecUsageTypeVTPM)
+	Target string // the iSCSI target (SecUsageTypeISCSI)
+}
+
+// ID returns the usage ID of the secret, which can be used to look it up with
+// LookupSecretByUsage.
+func (usage SecretUsage) ID() string {
+	switch usage.Type {
+	case SecUsageTypeVolume:
+		return usage.Volume
+	case SecUsageTypeISCSI:
+		return usage.Target
+	default:
+		return usage.Name
+	}
+}
+
 // Secret holds a libvirt secret. There are no exported fields.
 type Secret struct {
-	log       *log.Logger
+	log       *logger
+	ref       *objectRef
 	virSecret C.virSecretPtr
 }
 
 // Free releases the secret handle. The underlying secret continues to exist.
 func (sec Secret) Free() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
+	if !sec.ref.release() {
+		sec.log.Println("secret already freed")
+		return nil
+	}
+
 	sec.log.Println("freeing secret...")
 	cRet := C.virSecretFree(sec.virSecret)
 	ret := int(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -58,13 +95,19 @@ func (sec Secret) Free() error {
 // Undefine deletes the specified secret. This does not free the associated
 // "Secret" object.
 func (sec Secret) Undefine() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
 	sec.log.Println("undefining secret...")
 	cRet := C.virSecretUndefine(sec.virSecret)
 	ret := int(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -75,6 +118,12 @@ func (sec Secret) Undefine() error {
 
 // UUID fetches the UUID of the secret.
 func (sec Secret) UUID() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
 	cUUID := (*C.char)(C.malloc(C.size_t(C.VIR_UUID_STRING_BUFLEN)))
 	defer C.free(unsafe.Pointer(cUUID))
 
@@ -84,7 +133,7 @@ func (sec Secret) UUID() (string, error) {
 
 	if ret == -1 {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -96,12 +145,18 @@ func (sec Secret) UUID() (string, error) {
 
 // XML fetches an XML document describing attributes of the secret.
 func (sec Secret) XML() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
 	sec.log.Println("reading secret XML...")
 	cXML := C.virSecretGetXMLDesc(sec.virSecret, 0)
 
 	if cXML == nil {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cXML))
@@ -120,12 +175,18 @@ func (sec Secret) XML() (string, error) {
 // within the set of all secrets sharing the same usage type. ie, there shall
 // only ever be one secret for each volume path.
 func (sec Secret) UsageID() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
 	sec.log.Println("reading secret usage ID...")
 	cUsageID := C.virSecretGetUsageID(sec.virSecret)
 
 	if cUsageID == nil {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -141,12 +202,18 @@ func (sec Secret) UsageID() (string, error) {
 // values may be added to this enumeration in the future, so callers should
 // expect to see usage types they do not explicitly know about.
 func (sec Secret) UsageType() (SecretUsageType, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
 	sec.log.Println("reading secret usage type...")
 	cUsageType := C.virSecretGetUsageType(sec.virSecret)
 
 	if cUsageType == -1 {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -157,18 +224,85 @@ func (sec Secret) UsageType() (SecretUsageType, error) {
 	return usageType, nil
 }
 
-// SetValue sets the value of a secret.
+// secretUsageTypes maps the usage types in the secret XML to SecretUsageType.
+var secretUsageTypes = map[string]SecretUsageType{
+	"volume": SecUsageTypeVolume,
+	"ceph":   SecUsageTypeCeph,
+	"iscsi":  SecUsageTypeISCSI,
+	"tls":    SecUsageTypeTLS,
+	"vtpm":   SecUsageTypeVTPM,
+}
+
+// Usage provides the usage of the secret, parsed from its XML. If the secret
+// has no usage, the returned type is SecUsageTypeNone.
+func (sec Secret) Usage() (SecretUsage, error) {
+	xmlDesc, err := sec.XML()
+	if err != nil {
+		return SecretUsage{}, err
+	}
+
+	var desc struct {
+		Usage struct {
+			Type   string `xml:"type,attr"`
+			Volume string `xml:"volume"`
+			Name   string `xml:"name"`
+			Target string `xml:"target"`
+		} `xml:"usage"`
+	}
+
+	if err = xml.Unmarshal([]byte(xmlDesc), &desc); err != nil {
+		return SecretUsage{}, err
+	}
+
+	usage := SecretUsage{
+		Type: secretUsageTypes[desc.Usage.Type],
+	}
+
+	switch usage.Type {
+	case SecUsageTypeVolume:
+		usage.Volume = desc.Usage.Volume
+	case SecUsageTypeISCSI:
+		usage.Target = desc.Usage.Target
+	case SecUsageTypeCeph, SecUsageTypeTLS, SecUsageTypeVTPM:
+		usage.Name = desc.Usage.Name
+	}
+
+	return usage, nil
+}
+
+// SetValue sets the value of a secret. The value is copied to a temporary
+// buffer, which is zeroed afterwards; see SetValueBytes.
 func (sec Secret) SetValue(value string) error {
-	cSize := C.size_t(len(value))
-	cValue := (*C.uchar)(unsafe.Pointer(C.CString(value)))
+	buf := []byte(value)
+	defer zeroBytes(buf)
+
+	return sec.SetValueBytes(buf)
+}
 
-	sec.log.Printf("setting secret value (%v)...\n", value)
-	cRet := C.virSecretSetValue(sec.virSecret, cValue, cSize, 0)
+// SetValueBytes sets the value of a secret. The value is never logged, and the
+// C buffer it's copied to is zeroed before being freed. "value" itself is left
+// untouched, so the caller should zero it when it's no longer needed.
+func (sec Secret) SetValueBytes(value []byte) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
+	size := len(value)
+	cValue := C.malloc(C.size_t(size + 1))
+	defer C.free(cValue)
+	defer zeroCBuffer(cValue, size)
+
+	copy(cBytes(cValue, size), value)
+
+	sec.log.Printf("setting secret value (%v bytes)...\n", size)
+	cRet := C.virSecretSetValue(sec.virSecret, (*C.uchar)(cValue), C.size_t(size), 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -177,8 +311,29 @@ func (sec Secret) SetValue(value string) error {
 	return nil
 }
 
-// Value fetches the value of a secret.
+// Value fetches the value of a secret. The value is copied to a string, which
+// can't be zeroed; use ValueBytes to control how long the value is kept in
+// memory.
 func (sec Secret) Value() (string, error) {
+	value, err := sec.ValueBytes()
+	if err != nil {
+		return "", err
+	}
+	defer value.Zero()
+
+	return string(value), nil
+}
+
+// ValueBytes fetches the value of a secret. The value is never logged, and the
+// C buffer returned by libvirt is zeroed before being freed. The caller should
+// call Zero on the returned value when it's no longer needed.
+func (sec Secret) ValueBytes() (SecretValue, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
 	var cSize C.size_t
 
 	sec.log.Println("reading secret value...")
@@ -186,13 +341,16 @@ func (sec Secret) Value() (string, error) {
 
 	if cValue == nil {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
-		return "", err
+		call.error(err)
+		return nil, err
 	}
 	defer C.free(unsafe.Pointer(cValue))
+	defer zeroCBuffer(unsafe.Pointer(cValue), int(cSize))
 
-	value := string(C.GoBytes(unsafe.Pointer(cValue), C.int(cSize)))
-	sec.log.Printf("value: %v\n", value)
+	value := make(SecretValue, int(cSize))
+	copy(value, cBytes(unsafe.Pointer(cValue), int(cSize)))
+
+	sec.log.Printf("value read (%v bytes)\n", len(value))
 
 	return value, nil
 }
@@ -206,16 +364,23 @@ func (sec Secret) Value() (string, error) {
 // all threads have finished using it. ie, each new thread using a secret would
 // increment the reference count.
 func (sec Secret) Ref() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(sec.ref)
+	call := sec.log.startCall()
+	defer call.finish()
+
 	sec.log.Println("incrementing secret's reference count...")
 	cRet := C.virSecretRef(sec.virSecret)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		sec.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
+	sec.ref.acquire()
 	sec.log.Println("reference count incremented")
 
 	return nil
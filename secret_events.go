@@ -0,0 +1,133 @@
+package libvirt
+
+/*
+#include <stdlib.h>
+#include <libvirt/libvirt.h>
+
+int virConnectSecretEventRegisterLifecycleWrapper(virConnectPtr conn, virSecretPtr secret, long callbackID);
+int virConnectSecretEventRegisterValueChangedWrapper(virConnectPtr conn, virSecretPtr secret, long callbackID);
+*/
+import "C"
+import (
+	"runtime"
+	"unsafe"
+)
+
+// SecretEventLifecycleType describes a secret event.
+type SecretEventLifecycleType int32
+
+// Possible values for SecretEventLifecycleType. SecEventValueChanged isn't a
+// libvirt lifecycle event; it's delivered when the secret value changes.
+const (
+	SecEventDefined      SecretEventLifecycleType = C.VIR_SECRET_EVENT_DEFINED
+	SecEventUndefined    SecretEventLifecycleType = C.VIR_SECRET_EVENT_UNDEFINED
+	SecEventValueChanged SecretEventLifecycleType = nonLifecycleEvent
+)
+
+// SecretEvent is delivered when a secret is defined or undefined, or when its
+// value changes. "Detail" is currently always 0.
+type SecretEvent struct {
+	SecretUUID string
+	UsageType  SecretUsageType
+	UsageID    string
+	Event      SecretEventLifecycleType
+	Detail     int32
+}
+
+// secretEventHandler delivers secret events to a channel. It's registered for
+// both lifecycle and value changed events.
+type secretEventHandler struct {
+	eventHandler
+	events chan SecretEvent
+}
+
+func (h *secretEventHandler) deliver(cSecret C.virSecretPtr, event SecretEvent) {
+	cUUID := (*C.char)(C.malloc(C.size_t(C.VIR_UUID_STRING_BUFLEN)))
+	defer C.free(unsafe.Pointer(cUUID))
+
+	if C.virSecretGetUUIDString(cSecret, cUUID) == 0 {
+		event.SecretUUID = C.GoString(cUUID)
+	}
+
+	if cUsageType := C.virSecretGetUsageType(cSecret); cUsageType != -1 {
+		event.UsageType = SecretUsageType(cUsageType)
+	}
+
+	if cUsageID := C.virSecretGetUsageID(cSecret); cUsageID != nil {
+		event.UsageID = C.GoString(cUsageID)
+	}
+
+	select {
+	case h.events <- event:
+	default:
+	}
+}
+
+// SubscribeSecretEvents starts delivering the lifecycle and value changed
+// events of the secret "sec" to the returned channel. If "sec" is nil, the
+// events of every secret are delivered. Events are dropped if the channel is
+// full, so it should be drained constantly. The channel is closed after the
+// subscription is closed.
+// Events are only delivered while the event loop is running (see
+// StartEventLoop), which must happen before the connection is opened.
+func (conn Connection) SubscribeSecretEvents(sec *Secret) (<-chan SecretEvent, *EventSubscription, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	var cSecret C.virSecretPtr
+	if sec != nil {
+		cSecret = sec.virSecret
+	}
+
+	handler := &secretEventHandler{
+		events: make(chan SecretEvent, eventChannelSize),
+	}
+	handler.done = func() {
+		close(handler.events)
+	}
+
+	sub, err := conn.subscribeEvents("secret", handler,
+		func(libvirtCallbackID C.int) C.int {
+			return C.virConnectSecretEventDeregisterAny(conn.virConnect, libvirtCallbackID)
+		},
+		func(callbackID C.long) C.int {
+			return C.virConnectSecretEventRegisterLifecycleWrapper(conn.virConnect, cSecret, callbackID)
+		},
+		func(callbackID C.long) C.int {
+			return C.virConnectSecretEventRegisterValueChangedWrapper(conn.virConnect, cSecret, callbackID)
+		},
+	)
+	if err != nil {
+		call.error(err)
+		return nil, nil, err
+	}
+
+	return handler.events, sub, nil
+}
+
+//export secretEventLifecycleCallback
+func secretEventLifecycleCallback(cConn C.virConnectPtr, cSecret C.virSecretPtr, cEvent C.int, cDetail C.int, cCallbackID C.long) {
+	handler, ok := lookupCallback(int(cCallbackID)).(*secretEventHandler)
+	if !ok {
+		return
+	}
+
+	handler.deliver(cSecret, SecretEvent{
+		Event:  SecretEventLifecycleType(cEvent),
+		Detail: int32(cDetail),
+	})
+}
+
+//export secretEventValueChangedCallback
+func secretEventValueChangedCallback(cConn C.virConnectPtr, cSecret C.virSecretPtr, cCallbackID C.long) {
+	handler, ok := lookupCallback(int(cCallbackID)).(*secretEventHandler)
+	if !ok {
+		return
+	}
+
+	handler.deliver(cSecret, SecretEvent{
+		Event: SecEventValueChanged,
+	})
+}
@@ -0,0 +1,26 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+
+void secretEventLifecycleCallback(virConnectPtr conn, virSecretPtr secret, int event, int detail, long callbackID);
+void secretEventValueChangedCallback(virConnectPtr conn, virSecretPtr secret, long callbackID);
+void freeCallbackHelper(void *opaque);
+
+static void secretEventLifecycleCallbackHelper(virConnectPtr conn, virSecretPtr secret, int event, int detail, void *opaque) {
+    secretEventLifecycleCallback(conn, secret, event, detail, (long)opaque);
+}
+
+static void secretEventValueChangedCallbackHelper(virConnectPtr conn, virSecretPtr secret, void *opaque) {
+    secretEventValueChangedCallback(conn, secret, (long)opaque);
+}
+
+int virConnectSecretEventRegisterLifecycleWrapper(virConnectPtr conn, virSecretPtr secret, long callbackID) {
+    return virConnectSecretEventRegisterAny(conn, secret, VIR_SECRET_EVENT_ID_LIFECYCLE, VIR_SECRET_EVENT_CALLBACK(secretEventLifecycleCallbackHelper), (void *)callbackID, freeCallbackHelper);
+}
+
+int virConnectSecretEventRegisterValueChangedWrapper(virConnectPtr conn, virSecretPtr secret, long callbackID) {
+    return virConnectSecretEventRegisterAny(conn, secret, VIR_SECRET_EVENT_ID_VALUE_CHANGED, VIR_SECRET_EVENT_CALLBACK(secretEventValueChangedCallbackHelper), (void *)callbackID, freeCallbackHelper);
+}
+*/
+import "C"
@@ -1,7 +1,13 @@
 package libvirt
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/cd1/utils-golang"
 )
 
 func TestSecretInit(t *testing.T) {
@@ -63,6 +69,49 @@ func TestSecretValue(t *testing.T) {
 	}
 }
 
+func TestSecretValueBytes(t *testing.T) {
+	env := newTestEnvironment(t).withSecret()
+	defer env.cleanUp()
+
+	var logOutput bytes.Buffer
+	env.conn.SetLogger(NewWriterLogger(&logOutput))
+
+	secretText := "s3cr3t-" + utils.RandomString()
+	want := append([]byte{0, 1, 2, 0xff}, secretText...)
+
+	if err := env.sec.SetValueBytes(want); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := env.sec.ValueBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(value, want) {
+		t.Errorf("wrong secret value; got=%v bytes, want=%v bytes", len(value), len(want))
+	}
+
+	for _, format := range []string{"%v", "%s", "%q", "%x", "%#v", "%+v"} {
+		if str := fmt.Sprintf(format, value); str != "[REDACTED]" {
+			t.Errorf("secret value was not redacted with %q; got=%q, want=%q", format, str, "[REDACTED]")
+		}
+	}
+
+	if _, err = env.sec.Value(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(logOutput.String(), secretText) {
+		t.Errorf("secret value was logged: %q", logOutput.String())
+	}
+
+	value.Zero()
+	if !bytes.Equal(value, make([]byte, len(want))) {
+		t.Error("secret value was not zeroed")
+	}
+}
+
 func TestSecretRef(t *testing.T) {
 	env := newTestEnvironment(t).withSecret()
 	defer env.cleanUp()
@@ -75,3 +124,88 @@ func TestSecretRef(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestSecretUsage(t *testing.T) {
+	env := newTestEnvironment(t).withSecret()
+	defer env.cleanUp()
+
+	usage, err := env.sec.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.Type != SecUsageTypeCeph || usage.Name != env.secData.UsageName || usage.ID() != env.secData.UsageName {
+		t.Errorf("unexpected secret usage; got=%+v, want type=%v, name=%v", usage, SecUsageTypeCeph, env.secData.UsageName)
+	}
+
+	tlsName := fmt.Sprintf("tls-%v", utils.RandomString())
+	tlsXML := fmt.Sprintf(`<secret ephemeral="yes" private="yes"><usage type="tls"><name>%v</name></usage></secret>`, tlsName)
+
+	tlsSecret, err := env.conn.DefineSecret(tlsXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tlsSecret.Free()
+	defer tlsSecret.Undefine()
+
+	foundSecret, err := env.conn.LookupSecretByUsage(SecUsageTypeTLS, tlsName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer foundSecret.Free()
+
+	if usage, err = foundSecret.Usage(); err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.Type != SecUsageTypeTLS || usage.Name != tlsName {
+		t.Errorf("unexpected TLS secret usage; got=%+v, want type=%v, name=%v", usage, SecUsageTypeTLS, tlsName)
+	}
+}
+
+func waitSecretEvent(t *testing.T, events <-chan SecretEvent, uuid string, want SecretEventLifecycleType) SecretEvent {
+	timeout := time.After(10 * time.Second)
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("the events channel was closed while waiting for event %v", want)
+			}
+
+			if event.SecretUUID == uuid && event.Event == want {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for secret event %v", want)
+		}
+	}
+}
+
+func TestSecretEvents(t *testing.T) {
+	if err := StartEventLoop(); err != nil {
+		t.Fatal(err)
+	}
+
+	env := newTestEnvironment(t)
+	defer env.cleanUp()
+
+	events, sub, err := env.conn.SubscribeSecretEvents(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	env.withSecret()
+
+	event := waitSecretEvent(t, events, env.secData.UUID, SecEventDefined)
+	if event.UsageType != env.secData.UsageType || event.UsageID != env.secData.UsageName {
+		t.Errorf("unexpected secret usage in event; got=%v (%v), want=%v (%v)", event.UsageType, event.UsageID, env.secData.UsageType, env.secData.UsageName)
+	}
+
+	if err = env.sec.SetValue(env.secData.Value); err != nil {
+		t.Fatal(err)
+	}
+
+	waitSecretEvent(t, events, env.secData.UUID, SecEventValueChanged)
+}
@@ -0,0 +1,57 @@
+package libvirt
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// redacted is printed instead of the contents of a SecretValue.
+const redacted = "[REDACTED]"
+
+// SecretValue holds the value of a secret. It can be used as a regular byte
+// slice, but it's never printed by the fmt package (any verb prints
+// "[REDACTED]"), so it can't leak into logs by accident.
+type SecretValue []byte
+
+// String returns "[REDACTED]".
+func (v SecretValue) String() string {
+	return redacted
+}
+
+// GoString returns "[REDACTED]", so "%#v" doesn't print the value either.
+func (v SecretValue) GoString() string {
+	return redacted
+}
+
+// Format prints "[REDACTED]" for every verb.
+func (v SecretValue) Format(f fmt.State, verb rune) {
+	f.Write([]byte(redacted))
+}
+
+// Zero overwrites the value with zeros. It should be called as soon as the
+// value is no longer needed.
+func (v SecretValue) Zero() {
+	zeroBytes(v)
+}
+
+// zeroBytes overwrites "b" with zeros.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// cBytes returns a byte slice which points to the "size" bytes of the C
+// buffer "p", without copying them.
+func cBytes(p unsafe.Pointer, size int) []byte {
+	if size == 0 {
+		return nil
+	}
+
+	return (*[1 << 30]byte)(p)[:size:size]
+}
+
+// zeroCBuffer overwrites the "size" bytes of the C buffer "p" with zeros.
+func zeroCBuffer(p unsafe.Pointer, size int) {
+	zeroBytes(cBytes(p, size))
+}
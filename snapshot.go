@@ -4,8 +4,8 @@ package libvirt
 // #include <libvirt/libvirt.h>
 import "C"
 import (
-	"log"
 	"reflect"
+	"runtime"
 	"unicode/utf8"
 	"unsafe"
 )
@@ -70,20 +70,32 @@ const (
 
 // Snapshot holds a libvirt domain snapshot. There are no exported fields.
 type Snapshot struct {
-	log         *log.Logger
+	log         *logger
+	ref         *objectRef
 	virSnapshot C.virDomainSnapshotPtr
 }
 
 // Free frees the domain snapshot object. The snapshot itself is not modified.
 // The data structure is freed and should not be used thereafter.
 func (snap Snapshot) Free() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
+	if !snap.ref.release() {
+		snap.log.Println("snapshot object already freed")
+		return nil
+	}
+
 	snap.log.Println("freeing snapshot object...")
 	cRet := C.virDomainSnapshotFree(snap.virSnapshot)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -94,13 +106,19 @@ func (snap Snapshot) Free() error {
 
 // Delete deletes the snapshot.
 func (snap Snapshot) Delete(flags SnapshotDeleteFlag) error {
-	snap.log.Printf("deleting snapshot (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
+	snap.log.with(Field{FieldFlags, flags}).Println("deleting snapshot...")
 	cRet := C.virDomainSnapshotDelete(snap.virSnapshot, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -111,12 +129,18 @@ func (snap Snapshot) Delete(flags SnapshotDeleteFlag) error {
 
 // Name gets the public name for that snapshot.
 func (snap Snapshot) Name() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
 	snap.log.Println("reading snapshot name...")
 	cName := C.virDomainSnapshotGetName(snap.virSnapshot)
 
 	if cName == nil {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -128,17 +152,24 @@ func (snap Snapshot) Name() (string, error) {
 
 // Parent gets the parent snapshot for "snap", if any.
 func (snap Snapshot) Parent() (Snapshot, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
 	snap.log.Println("reading snapshot parent...")
 	cParent := C.virDomainSnapshotGetParent(snap.virSnapshot, 0)
 	if cParent == nil {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return Snapshot{}, err
 	}
 
 	parent := Snapshot{
-		log:         snap.log,
+		log:         snap.log.with(Field{FieldObject, objectSnapshot}),
 		virSnapshot: cParent,
+		ref:         newSnapshotRef(cParent),
 	}
 
 	snap.log.Println("parent obtained")
@@ -148,11 +179,17 @@ func (snap Snapshot) Parent() (Snapshot, error) {
 
 // XML provides an XML description of the domain snapshot.
 func (snap Snapshot) XML(flags DomainXMLFlag) (string, error) {
-	snap.log.Printf("reading snapshot XML (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
+	snap.log.with(Field{FieldFlags, flags}).Println("reading snapshot XML...")
 	cXML := C.virDomainSnapshotGetXMLDesc(snap.virSnapshot, C.uint(flags))
 	if cXML == nil {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cXML))
@@ -166,13 +203,19 @@ func (snap Snapshot) XML(flags DomainXMLFlag) (string, error) {
 // HasMetadata determines if the given snapshot is associated with libvirt
 // metadata that would prevent the deletion of the domain.
 func (snap Snapshot) HasMetadata() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
 	snap.log.Println("checking whether snapshot has metadata...")
 	cRet := C.virDomainSnapshotHasMetadata(snap.virSnapshot, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -190,13 +233,19 @@ func (snap Snapshot) HasMetadata() (bool, error) {
 // IsCurrent determines if the given snapshot is the domain's current snapshot.
 // See also "<Domain>.HasCurrentSnapshot".
 func (snap Snapshot) IsCurrent() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
 	snap.log.Println("checking whether snapshot is current...")
 	cRet := C.virDomainSnapshotIsCurrent(snap.virSnapshot, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -215,16 +264,23 @@ func (snap Snapshot) IsCurrent() (bool, error) {
 // to this method, there shall be a corresponding call to "<Snapshot>.Free" to
 // release the reference count, once the caller no longer needs the reference to this object.
 func (snap Snapshot) Ref() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
 	snap.log.Println("incrementing snapshot's reference count...")
 	cRet := C.virDomainSnapshotRef(snap.virSnapshot)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
+	snap.ref.acquire()
 	snap.log.Println("reference count incremented")
 
 	return nil
@@ -244,16 +300,22 @@ func (snap Snapshot) Ref() error {
 // it is possible to select an impossible combination, in that case a hypervisor
 // may return either 0 or an error.
 func (snap Snapshot) ListChildren(flags SnapshotListFlag) ([]Snapshot, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
 	var cSnaps []C.virDomainSnapshotPtr
 	snapsSH := (*reflect.SliceHeader)(unsafe.Pointer(&cSnaps))
 
-	snap.log.Printf("reading snapshot children (flags = %v)...\n", flags)
+	snap.log.with(Field{FieldFlags, flags}).Println("reading snapshot children...")
 	cRet := C.virDomainSnapshotListAllChildren(snap.virSnapshot, (**C.virDomainSnapshotPtr)(unsafe.Pointer(&snapsSH.Data)), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return nil, err
 	}
 	defer C.free(unsafe.Pointer(snapsSH.Data))
@@ -265,8 +327,9 @@ func (snap Snapshot) ListChildren(flags SnapshotListFlag) ([]Snapshot, error) {
 
 	for i := range snaps {
 		snaps[i] = Snapshot{
-			log:         snap.log,
+			log:         snap.log.with(Field{FieldObject, objectSnapshot}),
 			virSnapshot: cSnaps[i],
+			ref:         newSnapshotRef(cSnaps[i]),
 		}
 	}
 
@@ -304,13 +367,19 @@ func (snap Snapshot) ListChildren(flags SnapshotListFlag) ([]Snapshot, error) {
 // inactive snapshots with a "flags" request to start the domain after
 // the revert.
 func (snap Snapshot) Revert(flags SnapshotRevertFlag) error {
-	snap.log.Printf("reverting to snapshot (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(snap.ref)
+	call := snap.log.startCall()
+	defer call.finish()
+
+	snap.log.with(Field{FieldFlags, flags}).Println("reverting to snapshot...")
 	cRet := C.virDomainRevertToSnapshot(snap.virSnapshot, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		snap.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -4,8 +4,8 @@ package libvirt
 // #include <libvirt/libvirt.h>
 import "C"
 import (
-	"log"
 	"reflect"
+	"runtime"
 	"unicode/utf8"
 	"unsafe"
 )
@@ -89,20 +89,32 @@ const (
 
 // StoragePool holds a libvirt storage pool. There are no exported fields.
 type StoragePool struct {
-	log            *log.Logger
+	log            *logger
+	ref            *objectRef
 	virStoragePool C.virStoragePoolPtr
 }
 
 // Free frees a storage pool object, releasing all memory associated with it.
 // Does not change the state of the pool on the host.
 func (pool StoragePool) Free() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
+	if !pool.ref.release() {
+		pool.log.Println("storage pool object already freed")
+		return nil
+	}
+
 	pool.log.Println("freeing storage pool object...")
 	cRet := C.virStoragePoolFree(pool.virStoragePool)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -113,13 +125,19 @@ func (pool StoragePool) Free() error {
 
 // Undefine undefines an inactive storage pool.
 func (pool StoragePool) Undefine() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	pool.log.Println("undefining storage pool...")
 	cRet := C.virStoragePoolUndefine(pool.virStoragePool)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -130,13 +148,19 @@ func (pool StoragePool) Undefine() error {
 
 // Create starts an inactive storage pool.
 func (pool StoragePool) Create() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	pool.log.Println("creating storage pool...")
 	cRet := C.virStoragePoolCreate(pool.virStoragePool, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -150,13 +174,19 @@ func (pool StoragePool) Create() error {
 // persistent config it can later be restarted with "Create". This does not free
 // the associated StoragePool object.
 func (pool StoragePool) Destroy() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	pool.log.Println("destroying storage pool...")
 	cRet := C.virStoragePoolDestroy(pool.virStoragePool)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -168,13 +198,19 @@ func (pool StoragePool) Destroy() error {
 // Delete deletes the underlying pool resources. This is a non-recoverable
 // operation. The StoragePool object itself is not free'd.
 func (pool StoragePool) Delete(flags StoragePoolDeleteFlag) error {
-	pool.log.Printf("deleting storage pool (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
+	pool.log.with(Field{FieldFlags, flags}).Println("deleting storage pool...")
 	cRet := C.virStoragePoolDelete(pool.virStoragePool, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -185,13 +221,19 @@ func (pool StoragePool) Delete(flags StoragePoolDeleteFlag) error {
 
 // IsActive determines if the storage pool is currently running.
 func (pool StoragePool) IsActive() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	pool.log.Println("checking whether storage pool is active...")
 	cRet := C.virStoragePoolIsActive(pool.virStoragePool)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -209,13 +251,19 @@ func (pool StoragePool) IsActive() (bool, error) {
 // IsPersistent determines if the storage pool has a persistent configuration
 // which means it will still exist after shutting down.
 func (pool StoragePool) IsPersistent() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	pool.log.Println("checking whether storage pool is persistent...")
 	cRet := C.virStoragePoolIsPersistent(pool.virStoragePool)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -232,12 +280,18 @@ func (pool StoragePool) IsPersistent() (bool, error) {
 
 // Name fetches the locally unique name of the storage pool.
 func (pool StoragePool) Name() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	pool.log.Println("reading storage pool name...")
 	cName := C.virStoragePoolGetName(pool.virStoragePool)
 
 	if cName == nil {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -249,6 +303,12 @@ func (pool StoragePool) Name() (string, error) {
 
 // UUID fetches the globally unique ID of the storage pool as a string.
 func (pool StoragePool) UUID() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	cUUID := (*C.char)(C.malloc(C.size_t(C.VIR_UUID_STRING_BUFLEN)))
 	defer C.free(unsafe.Pointer(cUUID))
 
@@ -258,7 +318,7 @@ func (pool StoragePool) UUID() (string, error) {
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -272,12 +332,18 @@ func (pool StoragePool) UUID() (string, error) {
 // is suitable for later feeding back into the
 // "<Connection>.CreateStoragePool" method.
 func (pool StoragePool) XML(flags StorageXMLFlag) (string, error) {
-	pool.log.Printf("reading storage pool XML (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
+	pool.log.with(Field{FieldFlags, flags}).Println("reading storage pool XML...")
 	cXML := C.virStoragePoolGetXMLDesc(pool.virStoragePool, C.uint(flags))
 
 	if cXML == nil {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cXML))
@@ -290,6 +356,12 @@ func (pool StoragePool) XML(flags StorageXMLFlag) (string, error) {
 
 // InfoState extracts the storage pool state.
 func (pool StoragePool) InfoState() (StoragePoolState, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virStoragePoolInfo
 
 	pool.log.Println("reading storage pool state...")
@@ -298,7 +370,7 @@ func (pool StoragePool) InfoState() (StoragePoolState, error) {
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -310,6 +382,12 @@ func (pool StoragePool) InfoState() (StoragePoolState, error) {
 
 // InfoCapacity extracts the storage pool logical size (bytes).
 func (pool StoragePool) InfoCapacity() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virStoragePoolInfo
 
 	pool.log.Println("reading storage pool capacity...")
@@ -318,7 +396,7 @@ func (pool StoragePool) InfoCapacity() (uint64, error) {
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -330,6 +408,12 @@ func (pool StoragePool) InfoCapacity() (uint64, error) {
 
 // InfoAllocation extracts the storage pool current allocation (bytes).
 func (pool StoragePool) InfoAllocation() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virStoragePoolInfo
 
 	pool.log.Println("reading storage pool allocation...")
@@ -338,7 +422,7 @@ func (pool StoragePool) InfoAllocation() (uint64, error) {
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -350,6 +434,12 @@ func (pool StoragePool) InfoAllocation() (uint64, error) {
 
 // InfoAvailable extracts the storage pool remaining free space (bytes)
 func (pool StoragePool) InfoAvailable() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virStoragePoolInfo
 
 	pool.log.Println("reading storage pool available space...")
@@ -358,7 +448,7 @@ func (pool StoragePool) InfoAvailable() (uint64, error) {
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -371,6 +461,12 @@ func (pool StoragePool) InfoAvailable() (uint64, error) {
 // Autostart fetches the value of the autostart flag, which determines whether
 // the pool is automatically started at boot time.
 func (pool StoragePool) Autostart() (bool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	var cAutostart C.int
 
 	pool.log.Println("checking whether storage pool autostarts...")
@@ -379,7 +475,7 @@ func (pool StoragePool) Autostart() (bool, error) {
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return false, err
 	}
 
@@ -396,6 +492,12 @@ func (pool StoragePool) Autostart() (bool, error) {
 
 // SetAutostart sets the autostart flag.
 func (pool StoragePool) SetAutostart(autostart bool) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	var autostartInt int32
 	if autostart {
 		pool.log.Println("enabling storage pool autostart...")
@@ -410,7 +512,7 @@ func (pool StoragePool) SetAutostart(autostart bool) error {
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -425,13 +527,19 @@ func (pool StoragePool) SetAutostart(autostart bool) error {
 
 // Build builds the underlying storage pool.
 func (pool StoragePool) Build(flags StoragePoolBuildFlag) error {
-	pool.log.Printf("building storage pool (flags = %v)...\n", flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
+	pool.log.with(Field{FieldFlags, flags}).Println("building storage pool...")
 	cRet := C.virStoragePoolBuild(pool.virStoragePool, C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -444,13 +552,19 @@ func (pool StoragePool) Build(flags StoragePoolBuildFlag) error {
 // communicating with a remote server, and/or initializing new devices at the
 // OS layer.
 func (pool StoragePool) Refresh() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	pool.log.Println("refreshing storage pool...")
 	cRet := C.virStoragePoolRefresh(pool.virStoragePool, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -468,22 +582,36 @@ func (pool StoragePool) Refresh() error {
 // all threads have finished using it. ie, each new thread using a pool would
 // increment the reference count.
 func (pool StoragePool) Ref() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	pool.log.Println("incrementing storage pool's reference count...")
 	cRet := C.virStoragePoolRef(pool.virStoragePool)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
+	pool.ref.acquire()
+
 	return nil
 }
 
 // ListStorageVolumes collects the list of storage volumes, and allocate an
 // array to store those objects.
 func (pool StoragePool) ListStorageVolumes() ([]StorageVolume, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	var cStorageVolumes []C.virStorageVolPtr
 	cStorageVolumesSH := (*reflect.SliceHeader)(unsafe.Pointer(&cStorageVolumes))
 
@@ -493,7 +621,7 @@ func (pool StoragePool) ListStorageVolumes() ([]StorageVolume, error) {
 
 	if ret == -1 {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return nil, err
 	}
 	defer C.free(unsafe.Pointer(cStorageVolumesSH.Data))
@@ -504,8 +632,9 @@ func (pool StoragePool) ListStorageVolumes() ([]StorageVolume, error) {
 	storageVolumes := make([]StorageVolume, ret)
 	for i, cVol := range cStorageVolumes {
 		storageVolumes[i] = StorageVolume{
-			log:           pool.log,
+			log:           pool.log.with(Field{FieldObject, objectStorageVolume}),
 			virStorageVol: cVol,
+			ref:           newStorageVolumeRef(cVol),
 		}
 	}
 
@@ -522,23 +651,30 @@ func (pool StoragePool) ListStorageVolumes() ([]StorageVolume, error) {
 // "Free" should be used to free the resources after the storage volume object
 // is no longer needed.
 func (pool StoragePool) CreateStorageVolume(xml string, flags StorageVolumeCreateFlag) (StorageVolume, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(xml)
 	defer C.free(unsafe.Pointer(cXML))
 
-	pool.log.Printf("creating storage volume (flags = %v)...\n", flags)
+	pool.log.with(Field{FieldFlags, flags}).Println("creating storage volume...")
 	cVol := C.virStorageVolCreateXML(pool.virStoragePool, cXML, C.uint(flags))
 
 	if cVol == nil {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StorageVolume{}, err
 	}
 
 	pool.log.Println("volume created")
 
 	storageVolume := StorageVolume{
-		log:           pool.log,
+		log:           pool.log.with(Field{FieldObject, objectStorageVolume}),
 		virStorageVol: cVol,
+		ref:           newStorageVolumeRef(cVol),
 	}
 
 	return storageVolume, nil
@@ -553,23 +689,30 @@ func (pool StoragePool) CreateStorageVolume(xml string, flags StorageVolumeCreat
 // "Free" should be used to free the resources after the storage volume object
 // is no longer needed.
 func (pool StoragePool) CreateStorageVolumeFrom(xml string, cloneVol StorageVolume, flags StorageVolumeCreateFlag) (StorageVolume, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	cXML := C.CString(xml)
 	defer C.free(unsafe.Pointer(cXML))
 
-	pool.log.Printf("creating storage volume from another volume (flags = %v)...\n", flags)
+	pool.log.with(Field{FieldFlags, flags}).Println("creating storage volume from another volume...")
 	cVol := C.virStorageVolCreateXMLFrom(pool.virStoragePool, cXML, cloneVol.virStorageVol, C.uint(flags))
 
 	if cVol == nil {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StorageVolume{}, err
 	}
 
 	pool.log.Println("volume created")
 
 	storageVolume := StorageVolume{
-		log:           pool.log,
+		log:           pool.log.with(Field{FieldObject, objectStorageVolume}),
 		virStorageVol: cVol,
+		ref:           newStorageVolumeRef(cVol),
 	}
 
 	return storageVolume, nil
@@ -580,6 +723,12 @@ func (pool StoragePool) CreateStorageVolumeFrom(xml string, cloneVol StorageVolu
 // "Free" should be used to free the resources after the storage volume object
 // is no longer needed.
 func (pool StoragePool) LookupStorageVolumeByName(name string) (StorageVolume, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(pool.ref)
+	call := pool.log.startCall()
+	defer call.finish()
+
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
 
@@ -588,16 +737,27 @@ func (pool StoragePool) LookupStorageVolumeByName(name string) (StorageVolume, e
 
 	if cVol == nil {
 		err := LastError()
-		pool.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StorageVolume{}, err
 	}
 
 	pool.log.Println("volume found")
 
 	vol := StorageVolume{
-		log:           pool.log,
+		log:           pool.log.with(Field{FieldObject, objectStorageVolume}, Field{FieldName, name}),
 		virStorageVol: cVol,
+		ref:           newStorageVolumeRef(cVol),
 	}
 
 	return vol, nil
 }
+
+// connection returns the connection which owns the storage pool. It doesn't
+// hold a new reference, so it must not be closed nor used after the pool is
+// freed.
+func (pool StoragePool) connection() Connection {
+	return Connection{
+		log:        pool.log.with(Field{FieldObject, objectConnection}),
+		virConnect: C.virStoragePoolGetConnect(pool.virStoragePool),
+	}
+}
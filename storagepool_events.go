@@ -0,0 +1,149 @@
+package libvirt
+
+/*
+#include <stdlib.h>
+#include <libvirt/libvirt.h>
+
+int virConnectStoragePoolEventRegisterLifecycleWrapper(virConnectPtr conn, virStoragePoolPtr pool, long callbackID);
+int virConnectStoragePoolEventRegisterRefreshWrapper(virConnectPtr conn, virStoragePoolPtr pool, long callbackID);
+*/
+import "C"
+import (
+	"runtime"
+	"unsafe"
+)
+
+// StoragePoolEventLifecycleType describes a storage pool event.
+type StoragePoolEventLifecycleType int32
+
+// Possible values for StoragePoolEventLifecycleType. PoolEventRefreshed isn't a
+// libvirt lifecycle event; it's delivered when the pool is refreshed.
+const (
+	PoolEventDefined   StoragePoolEventLifecycleType = C.VIR_STORAGE_POOL_EVENT_DEFINED
+	PoolEventUndefined StoragePoolEventLifecycleType = C.VIR_STORAGE_POOL_EVENT_UNDEFINED
+	PoolEventStarted   StoragePoolEventLifecycleType = C.VIR_STORAGE_POOL_EVENT_STARTED
+	PoolEventStopped   StoragePoolEventLifecycleType = C.VIR_STORAGE_POOL_EVENT_STOPPED
+	PoolEventCreated   StoragePoolEventLifecycleType = C.VIR_STORAGE_POOL_EVENT_CREATED
+	PoolEventDeleted   StoragePoolEventLifecycleType = C.VIR_STORAGE_POOL_EVENT_DELETED
+	PoolEventRefreshed StoragePoolEventLifecycleType = nonLifecycleEvent
+)
+
+// State returns the state the storage pool is in after the event, in terms of
+// StoragePoolState. It returns false if the event doesn't change the pool state
+// (e.g. PoolEventRefreshed).
+func (typ StoragePoolEventLifecycleType) State() (StoragePoolState, bool) {
+	switch typ {
+	case PoolEventDefined, PoolEventStopped:
+		return PoolStateInactive, true
+	case PoolEventStarted:
+		return PoolStateRunning, true
+	default:
+		return 0, false
+	}
+}
+
+// StoragePoolEvent is delivered when the lifecycle of a storage pool changes,
+// or when it's refreshed. "Detail" is currently always 0.
+type StoragePoolEvent struct {
+	PoolName string
+	PoolUUID string
+	Event    StoragePoolEventLifecycleType
+	Detail   int32
+}
+
+// storagePoolEventHandler delivers storage pool events to a channel. It's
+// registered for both lifecycle and refresh events.
+type storagePoolEventHandler struct {
+	eventHandler
+	events chan StoragePoolEvent
+}
+
+func (h *storagePoolEventHandler) deliver(cPool C.virStoragePoolPtr, event StoragePoolEvent) {
+	if cName := C.virStoragePoolGetName(cPool); cName != nil {
+		event.PoolName = C.GoString(cName)
+	}
+
+	cUUID := (*C.char)(C.malloc(C.size_t(C.VIR_UUID_STRING_BUFLEN)))
+	defer C.free(unsafe.Pointer(cUUID))
+
+	if C.virStoragePoolGetUUIDString(cPool, cUUID) == 0 {
+		event.PoolUUID = C.GoString(cUUID)
+	}
+
+	select {
+	case h.events <- event:
+	default:
+	}
+}
+
+// SubscribeStoragePoolEvents starts delivering the lifecycle and refresh
+// events of the storage pool "pool" to the returned channel. If "pool" is nil,
+// the events of every pool are delivered. Events are dropped if the channel is
+// full, so it should be drained constantly. The channel is closed after the
+// subscription is closed.
+// Events are only delivered while the event loop is running (see
+// StartEventLoop), which must happen before the connection is opened.
+// libvirt doesn't report storage volume events; a PoolEventRefreshed event
+// is the only sign that the volumes of a pool may have changed outside
+// libvirt.
+func (conn Connection) SubscribeStoragePoolEvents(pool *StoragePool) (<-chan StoragePoolEvent, *EventSubscription, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call := conn.log.startCall()
+	defer call.finish()
+
+	var cPool C.virStoragePoolPtr
+	if pool != nil {
+		cPool = pool.virStoragePool
+	}
+
+	handler := &storagePoolEventHandler{
+		events: make(chan StoragePoolEvent, eventChannelSize),
+	}
+	handler.done = func() {
+		close(handler.events)
+	}
+
+	sub, err := conn.subscribeEvents("storage pool", handler,
+		func(libvirtCallbackID C.int) C.int {
+			return C.virConnectStoragePoolEventDeregisterAny(conn.virConnect, libvirtCallbackID)
+		},
+		func(callbackID C.long) C.int {
+			return C.virConnectStoragePoolEventRegisterLifecycleWrapper(conn.virConnect, cPool, callbackID)
+		},
+		func(callbackID C.long) C.int {
+			return C.virConnectStoragePoolEventRegisterRefreshWrapper(conn.virConnect, cPool, callbackID)
+		},
+	)
+	if err != nil {
+		call.error(err)
+		return nil, nil, err
+	}
+
+	return handler.events, sub, nil
+}
+
+//export storagePoolEventLifecycleCallback
+func storagePoolEventLifecycleCallback(cConn C.virConnectPtr, cPool C.virStoragePoolPtr, cEvent C.int, cDetail C.int, cCallbackID C.long) {
+	handler, ok := lookupCallback(int(cCallbackID)).(*storagePoolEventHandler)
+	if !ok {
+		return
+	}
+
+	handler.deliver(cPool, StoragePoolEvent{
+		Event:  StoragePoolEventLifecycleType(cEvent),
+		Detail: int32(cDetail),
+	})
+}
+
+//export storagePoolEventRefreshCallback
+func storagePoolEventRefreshCallback(cConn C.virConnectPtr, cPool C.virStoragePoolPtr, cCallbackID C.long) {
+	handler, ok := lookupCallback(int(cCallbackID)).(*storagePoolEventHandler)
+	if !ok {
+		return
+	}
+
+	handler.deliver(cPool, StoragePoolEvent{
+		Event: PoolEventRefreshed,
+	})
+}
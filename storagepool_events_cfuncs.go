@@ -0,0 +1,26 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+
+void storagePoolEventLifecycleCallback(virConnectPtr conn, virStoragePoolPtr pool, int event, int detail, long callbackID);
+void storagePoolEventRefreshCallback(virConnectPtr conn, virStoragePoolPtr pool, long callbackID);
+void freeCallbackHelper(void *opaque);
+
+static void storagePoolEventLifecycleCallbackHelper(virConnectPtr conn, virStoragePoolPtr pool, int event, int detail, void *opaque) {
+    storagePoolEventLifecycleCallback(conn, pool, event, detail, (long)opaque);
+}
+
+static void storagePoolEventRefreshCallbackHelper(virConnectPtr conn, virStoragePoolPtr pool, void *opaque) {
+    storagePoolEventRefreshCallback(conn, pool, (long)opaque);
+}
+
+int virConnectStoragePoolEventRegisterLifecycleWrapper(virConnectPtr conn, virStoragePoolPtr pool, long callbackID) {
+    return virConnectStoragePoolEventRegisterAny(conn, pool, VIR_STORAGE_POOL_EVENT_ID_LIFECYCLE, VIR_STORAGE_POOL_EVENT_CALLBACK(storagePoolEventLifecycleCallbackHelper), (void *)callbackID, freeCallbackHelper);
+}
+
+int virConnectStoragePoolEventRegisterRefreshWrapper(virConnectPtr conn, virStoragePoolPtr pool, long callbackID) {
+    return virConnectStoragePoolEventRegisterAny(conn, pool, VIR_STORAGE_POOL_EVENT_ID_REFRESH, VIR_STORAGE_POOL_EVENT_CALLBACK(storagePoolEventRefreshCallbackHelper), (void *)callbackID, freeCallbackHelper);
+}
+*/
+import "C"
@@ -0,0 +1,162 @@
+package libvirt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ImageFormat represents the format of a disk image file.
+type ImageFormat string
+
+// Possible values for ImageFormat. ImageFormatAuto detects the format from the
+// image content.
+const (
+	ImageFormatAuto  ImageFormat = ""
+	ImageFormatRaw   ImageFormat = "raw"
+	ImageFormatQcow2 ImageFormat = "qcow2"
+)
+
+// qcow2Magic is found at the beginning of every qcow2 image.
+var qcow2Magic = []byte{'Q', 'F', 'I', 0xfb}
+
+// qcow2HeaderSize is the size of the part of the qcow2 header which is
+// inspected: magic, version, backing file offset and size, cluster bits and
+// virtual size.
+const qcow2HeaderSize = 32
+
+// ErrInvalidImage is returned by ImportVolume when the image file doesn't
+// match the requested format.
+var ErrInvalidImage = errors.New("invalid image file")
+
+// ErrImageBackingFile is returned by ImportVolume when a qcow2 image depends
+// on a backing file, which wouldn't be available inside the pool.
+var ErrImageBackingFile = errors.New("image has a backing file")
+
+// imageInfo describes an image file to be imported.
+type imageInfo struct {
+	format   ImageFormat
+	size     uint64 // the file size
+	capacity uint64 // the virtual disk size
+}
+
+// inspectImage reads the format and sizes of the image file "f". If "format"
+// is ImageFormatAuto, it's detected from the file content.
+func inspectImage(f *os.File, format ImageFormat) (imageInfo, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return imageInfo{}, err
+	}
+
+	if !stat.Mode().IsRegular() {
+		return imageInfo{}, ErrInvalidImage
+	}
+
+	info := imageInfo{
+		format:   format,
+		size:     uint64(stat.Size()),
+		capacity: uint64(stat.Size()),
+	}
+
+	header := make([]byte, qcow2HeaderSize)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return imageInfo{}, err
+	}
+
+	isQcow2 := n == qcow2HeaderSize && bytes.Equal(header[:4], qcow2Magic)
+
+	switch format {
+	case ImageFormatAuto:
+		info.format = ImageFormatRaw
+		if isQcow2 {
+			info.format = ImageFormatQcow2
+		}
+	case ImageFormatRaw:
+	case ImageFormatQcow2:
+		if !isQcow2 {
+			return imageInfo{}, ErrInvalidImage
+		}
+	default:
+		return imageInfo{}, ErrInvalidImage
+	}
+
+	if info.format == ImageFormatQcow2 {
+		if binary.BigEndian.Uint64(header[8:16]) != 0 {
+			return imageInfo{}, ErrImageBackingFile
+		}
+
+		info.capacity = binary.BigEndian.Uint64(header[24:32])
+	}
+
+	return info, nil
+}
+
+// ImportVolume creates a new volume called "name" in the pool, with the
+// content of the local image file "path". The image format ("raw" or "qcow2")
+// is detected if "format" is ImageFormatAuto, and the volume is sized after
+// the virtual disk size of the image. The content is uploaded with
+// UploadFrom, keeping the holes of sparse files; if anything fails, or if not
+// all bytes could be uploaded, the volume is deleted.
+func (pool StoragePool) ImportVolume(ctx context.Context, name string, path string, format ImageFormat) (StorageVolume, error) {
+	return pool.importVolume(ctx, name, path, format, nil)
+}
+
+// importVolume implements ImportVolume. If "check" is not nil, it's called
+// with the capacity of the new volume before it's created, and the import is
+// cancelled if it returns an error.
+func (pool StoragePool) importVolume(ctx context.Context, name string, path string, format ImageFormat, check func(capacity uint64) error) (StorageVolume, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return StorageVolume{}, err
+	}
+	defer file.Close()
+
+	info, err := inspectImage(file, format)
+	if err != nil {
+		return StorageVolume{}, err
+	}
+
+	if check != nil {
+		if err = check(info.capacity); err != nil {
+			return StorageVolume{}, err
+		}
+	}
+
+	volXML, err := xml.Marshal(newVolumeXML(name, info.capacity, string(info.format)))
+	if err != nil {
+		return StorageVolume{}, err
+	}
+
+	pool.log.Printf("importing %v image %v as volume %v...\n", info.format, path, name)
+
+	vol, err := pool.CreateStorageVolume(string(volXML), VolCreateDefault)
+	if err != nil {
+		return StorageVolume{}, err
+	}
+
+	var uploaded uint64
+	progress := func(transferred uint64) {
+		uploaded = transferred
+	}
+
+	err = vol.UploadFrom(ctx, file, 0, info.size, progress)
+	if err == nil && uploaded != info.size {
+		err = fmt.Errorf("incomplete image upload: %v of %v bytes", uploaded, info.size)
+	}
+
+	if err != nil {
+		vol.Delete()
+		vol.Free()
+		return StorageVolume{}, err
+	}
+
+	pool.log.Printf("image imported (%v bytes)\n", uploaded)
+
+	return vol, nil
+}
@@ -0,0 +1,325 @@
+package libvirt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// ErrQuotaExceeded can be used with errors.Is to detect a QuotaError.
+var ErrQuotaExceeded = errors.New("storage pool quota exceeded")
+
+// ErrInvalidCapacityUnit is returned when a volume XML description uses an
+// unknown capacity unit.
+var ErrInvalidCapacityUnit = errors.New("invalid capacity unit")
+
+// ErrCapacityOverflow is returned when the capacity in a volume XML
+// description doesn't fit in 64 bits, once converted to bytes.
+var ErrCapacityOverflow = errors.New("capacity overflow")
+
+// ErrInvalidOvercommitRatio is returned by StoragePoolGuard when its
+// OvercommitRatio isn't a positive number.
+var ErrInvalidOvercommitRatio = errors.New("invalid overcommit ratio")
+
+// StoragePoolUsage summarizes how much space of a pool is used, and how much
+// is promised to its volumes.
+type StoragePoolUsage struct {
+	Capacity         uint64 // the pool capacity
+	Allocation       uint64 // the space allocated in the pool
+	Available        uint64 // the space available in the pool
+	VolumeCapacity   uint64 // the sum of the capacities of the volumes
+	VolumeAllocation uint64 // the sum of the allocations of the volumes
+}
+
+// Usage refreshes the pool and sums the capacities and allocations of all its
+// volumes. Volumes which disappear while they're summed are skipped.
+func (pool StoragePool) Usage() (StoragePoolUsage, error) {
+	var usage StoragePoolUsage
+
+	if err := pool.Refresh(); err != nil {
+		return usage, err
+	}
+
+	var err error
+
+	if usage.Capacity, err = pool.InfoCapacity(); err != nil {
+		return usage, err
+	}
+
+	if usage.Allocation, err = pool.InfoAllocation(); err != nil {
+		return usage, err
+	}
+
+	if usage.Available, err = pool.InfoAvailable(); err != nil {
+		return usage, err
+	}
+
+	err = pool.ForEachStorageVolume(func(vol StorageVolume) error {
+		capacity, err := vol.InfoCapacity()
+		if err != nil {
+			return skipNotFound(err)
+		}
+
+		allocation, err := vol.InfoAllocation()
+		if err != nil {
+			return skipNotFound(err)
+		}
+
+		usage.VolumeCapacity += capacity
+		usage.VolumeAllocation += allocation
+
+		return nil
+	})
+
+	return usage, err
+}
+
+// QuotaError is returned by StoragePoolGuard when an operation would commit
+// more capacity than the pool allows.
+type QuotaError struct {
+	Pool      string // the pool name
+	Requested uint64 // the capacity requested by the operation
+	Committed uint64 // the capacity already committed to the volumes
+	Limit     uint64 // the maximum capacity which can be committed
+}
+
+// Shortfall returns how much capacity is missing for the operation to be
+// allowed.
+func (err *QuotaError) Shortfall() uint64 {
+	if err.Committed > err.Limit {
+		return err.Requested + (err.Committed - err.Limit)
+	}
+
+	return err.Requested - (err.Limit - err.Committed)
+}
+
+// Error makes QuotaError satisfy the "error" interface.
+func (err *QuotaError) Error() string {
+	return fmt.Sprintf("storage pool %v quota exceeded: requested %v bytes, committed %v of %v bytes (short of %v bytes)",
+		err.Pool, err.Requested, err.Committed, err.Limit, err.Shortfall())
+}
+
+// Is allows errors.Is to match a QuotaError with ErrQuotaExceeded.
+func (err *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// StoragePoolGuard wraps the operations which create or grow volumes in a
+// pool, rejecting them with a QuotaError if the capacity committed to the
+// volumes would exceed the pool capacity multiplied by OvercommitRatio. The
+// operations of the same guard are serialized, but nothing stops other guards
+// or programs from changing the pool meanwhile.
+type StoragePoolGuard struct {
+	Pool            StoragePool
+	OvercommitRatio float64 // e.g. 1.0 means no overcommit, 2.0 means up to twice the pool capacity
+
+	mutex sync.Mutex
+}
+
+// NewStoragePoolGuard creates a guard for "pool" with the given overcommit
+// ratio. ErrInvalidOvercommitRatio is returned if the ratio isn't positive.
+func NewStoragePoolGuard(pool StoragePool, overcommitRatio float64) (*StoragePoolGuard, error) {
+	if !validOvercommitRatio(overcommitRatio) {
+		return nil, ErrInvalidOvercommitRatio
+	}
+
+	return &StoragePoolGuard{
+		Pool:            pool,
+		OvercommitRatio: overcommitRatio,
+	}, nil
+}
+
+// validOvercommitRatio tells whether "ratio" can be used as an overcommit
+// ratio (i.e. it's a positive number).
+func validOvercommitRatio(ratio float64) bool {
+	return ratio > 0 && !math.IsInf(ratio, 1)
+}
+
+// check returns a QuotaError if "requested" more bytes can't be committed to
+// the pool volumes.
+func (g *StoragePoolGuard) check(requested uint64) error {
+	// the guard may have been created without NewStoragePoolGuard
+	if !validOvercommitRatio(g.OvercommitRatio) {
+		return ErrInvalidOvercommitRatio
+	}
+
+	usage, err := g.Pool.Usage()
+	if err != nil {
+		return err
+	}
+
+	limit := uint64(math.MaxUint64)
+	if l := float64(usage.Capacity) * g.OvercommitRatio; l < math.MaxUint64 {
+		limit = uint64(l)
+	}
+
+	if requested <= limit && usage.VolumeCapacity <= limit-requested {
+		return nil
+	}
+
+	name, _ := g.Pool.Name()
+
+	return &QuotaError{
+		Pool:      name,
+		Requested: requested,
+		Committed: usage.VolumeCapacity,
+		Limit:     limit,
+	}
+}
+
+// Check returns a QuotaError if "requested" more bytes can't be committed to
+// the pool volumes.
+func (g *StoragePoolGuard) Check(requested uint64) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.check(requested)
+}
+
+// CreateStorageVolume works like StoragePool.CreateStorageVolume, but checks
+// the capacity in "xml" against the quota first.
+func (g *StoragePoolGuard) CreateStorageVolume(xml string, flags StorageVolumeCreateFlag) (StorageVolume, error) {
+	capacity, err := volumeXMLCapacity(xml)
+	if err != nil {
+		return StorageVolume{}, err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err = g.check(capacity); err != nil {
+		return StorageVolume{}, err
+	}
+
+	return g.Pool.CreateStorageVolume(xml, flags)
+}
+
+// CreateStorageVolumeFrom works like StoragePool.CreateStorageVolumeFrom, but
+// checks the capacity in "xml" (or the capacity of "cloneVol", if "xml" has
+// none) against the quota first.
+func (g *StoragePoolGuard) CreateStorageVolumeFrom(xml string, cloneVol StorageVolume, flags StorageVolumeCreateFlag) (StorageVolume, error) {
+	capacity, err := volumeXMLCapacity(xml)
+	if err != nil {
+		return StorageVolume{}, err
+	}
+
+	if capacity == 0 {
+		if capacity, err = cloneVol.InfoCapacity(); err != nil {
+			return StorageVolume{}, err
+		}
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err = g.check(capacity); err != nil {
+		return StorageVolume{}, err
+	}
+
+	return g.Pool.CreateStorageVolumeFrom(xml, cloneVol, flags)
+}
+
+// CreateLinkedClone works like StoragePool.CreateLinkedClone, but checks the
+// capacity of "base" against the quota first.
+func (g *StoragePoolGuard) CreateLinkedClone(name string, base StorageVolume, format ImageFormat) (StorageVolume, error) {
+	capacity, err := base.InfoCapacity()
+	if err != nil {
+		return StorageVolume{}, err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err = g.check(capacity); err != nil {
+		return StorageVolume{}, err
+	}
+
+	return g.Pool.CreateLinkedClone(name, base, format)
+}
+
+// ImportVolume works like StoragePool.ImportVolume, but checks the virtual
+// size of the image against the quota before the volume is created.
+func (g *StoragePoolGuard) ImportVolume(ctx context.Context, name string, path string, format ImageFormat) (StorageVolume, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.Pool.importVolume(ctx, name, path, format, g.check)
+}
+
+// ResizeStorageVolume works like StorageVolume.Resize, but checks the capacity
+// being added to "vol" against the quota first. Shrinking is always allowed.
+func (g *StoragePoolGuard) ResizeStorageVolume(vol StorageVolume, capacity uint64, flags StorageVolumeResizeFlag) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	growth := capacity
+
+	if flags&VolResizeDelta == 0 {
+		current, err := vol.InfoCapacity()
+		if err != nil {
+			return err
+		}
+
+		growth = 0
+		if capacity > current {
+			growth = capacity - current
+		}
+	}
+
+	if flags&VolResizeShrink == 0 && growth > 0 {
+		if err := g.check(growth); err != nil {
+			return err
+		}
+	}
+
+	return vol.Resize(capacity, flags)
+}
+
+// capacityUnits maps the units accepted by libvirt to their size in bytes.
+var capacityUnits = map[string]uint64{
+	"":      1,
+	"b":     1,
+	"bytes": 1,
+	"kb":    1000,
+	"k":     1 << 10,
+	"kib":   1 << 10,
+	"mb":    1000 * 1000,
+	"m":     1 << 20,
+	"mib":   1 << 20,
+	"gb":    1000 * 1000 * 1000,
+	"g":     1 << 30,
+	"gib":   1 << 30,
+	"tb":    1000 * 1000 * 1000 * 1000,
+	"t":     1 << 40,
+	"tib":   1 << 40,
+	"pb":    1000 * 1000 * 1000 * 1000 * 1000,
+	"p":     1 << 50,
+	"pib":   1 << 50,
+	"eb":    1000 * 1000 * 1000 * 1000 * 1000 * 1000,
+	"e":     1 << 60,
+	"eib":   1 << 60,
+}
+
+// volumeXMLCapacity reads the capacity, in bytes, from a volume XML
+// description. It returns 0 if there's no capacity, and ErrCapacityOverflow if
+// it doesn't fit in 64 bits.
+func volumeXMLCapacity(xmlDesc string) (uint64, error) {
+	desc, err := parseVolumeXML(xmlDesc)
+	if err != nil {
+		return 0, err
+	}
+
+	unit, ok := capacityUnits[strings.ToLower(desc.Capacity.Unit)]
+	if !ok {
+		return 0, ErrInvalidCapacityUnit
+	}
+
+	if desc.Capacity.Value > math.MaxUint64/unit {
+		return 0, ErrCapacityOverflow
+	}
+
+	return desc.Capacity.Value * unit, nil
+}
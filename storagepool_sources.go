@@ -0,0 +1,143 @@
+package libvirt
+
+import (
+	"encoding/xml"
+)
+
+// StoragePoolSource describes where the data of a storage pool comes from, as
+// found by FindStoragePoolSourceList. Which fields are set depends on the pool
+// type: e.g. NFS sources have a host and a directory, while LVM sources have
+// devices, a volume group name and a format.
+type StoragePoolSource struct {
+	XMLName xml.Name                  `xml:"source"`
+	Hosts   []StoragePoolSourceHost   `xml:"host"`
+	Devices []StoragePoolSourceDevice `xml:"device"`
+	Dir     *StoragePoolSourceDir     `xml:"dir"`
+	Adapter *StoragePoolSourceAdapter `xml:"adapter"`
+	Name    string                    `xml:"name,omitempty"`
+	Format  *StoragePoolSourceFormat  `xml:"format"`
+}
+
+// StoragePoolSourceHost is a host which provides a storage pool source.
+type StoragePoolSourceHost struct {
+	Name string `xml:"name,attr"`
+	Port int    `xml:"port,attr,omitempty"`
+}
+
+// StoragePoolSourceDevice is a device which backs a storage pool source.
+type StoragePoolSourceDevice struct {
+	Path string `xml:"path,attr"`
+}
+
+// StoragePoolSourceDir is a directory which backs a storage pool source.
+type StoragePoolSourceDir struct {
+	Path string `xml:"path,attr"`
+}
+
+// StoragePoolSourceAdapter is a SCSI host adapter which backs a storage pool
+// source.
+type StoragePoolSourceAdapter struct {
+	Type   string `xml:"type,attr,omitempty"`
+	Name   string `xml:"name,attr,omitempty"`
+	Parent string `xml:"parent,attr,omitempty"`
+	WWNN   string `xml:"wwnn,attr,omitempty"`
+	WWPN   string `xml:"wwpn,attr,omitempty"`
+}
+
+// StoragePoolSourceFormat is the format of a storage pool source (e.g.
+// "lvm2", "nfs").
+type StoragePoolSourceFormat struct {
+	Type string `xml:"type,attr"`
+}
+
+// storagePoolSourcesXML is the XML returned by FindStoragePoolSources.
+type storagePoolSourcesXML struct {
+	XMLName xml.Name            `xml:"sources"`
+	Sources []StoragePoolSource `xml:"source"`
+}
+
+// storagePoolXML is the XML description of a storage pool defined from a
+// discovered source.
+type storagePoolXML struct {
+	XMLName xml.Name              `xml:"pool"`
+	Type    string                `xml:"type,attr"`
+	Name    string                `xml:"name"`
+	Source  StoragePoolSource     `xml:"source"`
+	Target  *storagePoolTargetXML `xml:"target"`
+}
+
+// storagePoolTargetXML is the target of a storage pool.
+type storagePoolTargetXML struct {
+	Path string `xml:"path"`
+}
+
+// parseStoragePoolSources parses the XML returned by FindStoragePoolSources.
+func parseStoragePoolSources(sourcesXML string) ([]StoragePoolSource, error) {
+	var sources storagePoolSourcesXML
+
+	if err := xml.Unmarshal([]byte(sourcesXML), &sources); err != nil {
+		return nil, err
+	}
+
+	return sources.Sources, nil
+}
+
+// FindStoragePoolSourceList works like FindStoragePoolSources, but the sources
+// are parsed into structs. "source" specifies where to look for the sources
+// (e.g. the NFS host); if it's nil, no source spec is sent to libvirt.
+func (conn Connection) FindStoragePoolSourceList(typ string, source *StoragePoolSource) ([]StoragePoolSource, error) {
+	var sourceXML string
+
+	if source != nil {
+		data, err := xml.Marshal(source)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceXML = string(data)
+	}
+
+	sourcesXML, err := conn.FindStoragePoolSources(typ, sourceXML)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseStoragePoolSources(sourcesXML)
+}
+
+// PoolXML builds the XML description of a storage pool of type "typ" (e.g.
+// "logical", "netfs") called "name", which uses the source. "targetPath" is
+// where the pool is made available on the host (e.g. the NFS mount point); it
+// may be empty for the pool types which don't need it.
+func (src StoragePoolSource) PoolXML(typ string, name string, targetPath string) (string, error) {
+	desc := storagePoolXML{
+		Type:   typ,
+		Name:   name,
+		Source: src,
+	}
+
+	if targetPath != "" {
+		desc.Target = &storagePoolTargetXML{Path: targetPath}
+	}
+
+	data, err := xml.Marshal(desc)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// DefineStoragePoolFromSource defines a storage pool of type "typ" called
+// "name", which uses the source "src" (usually found by
+// FindStoragePoolSourceList). See PoolXML for the meaning of the arguments.
+// "Free" should be used to free the resources after the storage pool object is
+// no longer needed.
+func (conn Connection) DefineStoragePoolFromSource(typ string, name string, targetPath string, src StoragePoolSource) (StoragePool, error) {
+	poolXML, err := src.PoolXML(typ, name, targetPath)
+	if err != nil {
+		return StoragePool{}, err
+	}
+
+	return conn.DefineStoragePool(poolXML)
+}
@@ -2,7 +2,14 @@ package libvirt
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/cd1/utils-golang"
 )
@@ -250,6 +257,329 @@ func TestStoragePoolLookupVolume(t *testing.T) {
 	}
 }
 
+func waitStoragePoolEvent(t *testing.T, events <-chan StoragePoolEvent, want StoragePoolEventLifecycleType) StoragePoolEvent {
+	timeout := time.After(10 * time.Second)
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("the events channel was closed while waiting for event %v", want)
+			}
+
+			if event.Event == want {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for storage pool event %v", want)
+		}
+	}
+}
+
+func TestStoragePoolEvents(t *testing.T) {
+	if err := StartEventLoop(); err != nil {
+		t.Fatal(err)
+	}
+
+	env := newTestEnvironment(t).withStoragePool()
+	defer env.cleanUp()
+
+	events, sub, err := env.conn.SubscribeStoragePoolEvents(env.pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = env.pool.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	event := waitStoragePoolEvent(t, events, PoolEventStarted)
+	if event.PoolName != env.poolData.Name || event.PoolUUID != env.poolData.UUID {
+		t.Errorf("unexpected storage pool in event; got=%v (%v), want=%v (%v)", event.PoolName, event.PoolUUID, env.poolData.Name, env.poolData.UUID)
+	}
+
+	if state, ok := event.Event.State(); !ok || state != PoolStateRunning {
+		t.Errorf("unexpected storage pool state after event; got=%v (%v), want=%v", state, ok, PoolStateRunning)
+	}
+
+	if err = env.pool.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitStoragePoolEvent(t, events, PoolEventRefreshed)
+
+	if err = env.pool.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitStoragePoolEvent(t, events, PoolEventStopped)
+
+	if err = sub.Close(); err != nil {
+		t.Error(err)
+	}
+
+	timeout := time.After(10 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-events:
+			closed = !ok
+		case <-timeout:
+			t.Fatal("the events channel was not closed after closing the subscription")
+		}
+	}
+}
+
+func TestStoragePoolImportVolume(t *testing.T) {
+	env := newTestEnvironment(t).withStoragePool()
+	defer env.cleanUp()
+
+	if err := env.pool.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := ioutil.TempFile("", "image-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	data := utils.RandomString()
+
+	if _, err = file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	name := "vol-" + utils.RandomString()
+
+	if _, err = env.pool.ImportVolume(context.Background(), name, file.Name(), ImageFormatQcow2); err != ErrInvalidImage {
+		t.Errorf("unexpected error importing a raw image as qcow2; got=%v, want=%v", err, ErrInvalidImage)
+	}
+
+	vol, err := env.pool.ImportVolume(context.Background(), name, file.Name(), ImageFormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vol.Free()
+	defer vol.Delete()
+
+	capacity, err := vol.InfoCapacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if capacity != uint64(len(data)) {
+		t.Errorf("unexpected imported volume capacity; got=%v, want=%v", capacity, len(data))
+	}
+
+	var buf bytes.Buffer
+
+	if err = vol.DownloadTo(context.Background(), &buf, 0, uint64(len(data)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := buf.String(); content != data {
+		t.Errorf("unexpected imported volume content; got=%v, want=%v", content, data)
+	}
+}
+
+// writeQcow2Header creates a temporary file which holds a qcow2 header, with
+// the given virtual size and backing file offset, and returns its name.
+func writeQcow2Header(t *testing.T, virtualSize uint64, backingFileOffset uint64) string {
+	file, err := ioutil.TempFile("", "image-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	header := make([]byte, qcow2HeaderSize)
+	copy(header, qcow2Magic)
+	binary.BigEndian.PutUint32(header[4:8], 3) // version
+	binary.BigEndian.PutUint64(header[8:16], backingFileOffset)
+	binary.BigEndian.PutUint32(header[20:24], 16) // cluster bits
+	binary.BigEndian.PutUint64(header[24:32], virtualSize)
+
+	if _, err = file.Write(header); err != nil {
+		os.Remove(file.Name())
+		t.Fatal(err)
+	}
+
+	return file.Name()
+}
+
+func TestStoragePoolImportVolumeQcow2(t *testing.T) {
+	const virtualSize = 1048576 // 1 MiB
+
+	path := writeQcow2Header(t, virtualSize, 0)
+	defer os.Remove(path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	info, err := inspectImage(file, ImageFormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.format != ImageFormatQcow2 {
+		t.Errorf("unexpected image format; got=%v, want=%v", info.format, ImageFormatQcow2)
+	}
+
+	if info.capacity != virtualSize {
+		t.Errorf("unexpected image capacity; got=%v, want=%v", info.capacity, virtualSize)
+	}
+
+	if info.size != qcow2HeaderSize {
+		t.Errorf("unexpected image size; got=%v, want=%v", info.size, qcow2HeaderSize)
+	}
+
+	env := newTestEnvironment(t).withStoragePool()
+	defer env.cleanUp()
+
+	if err = env.pool.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	backedPath := writeQcow2Header(t, virtualSize, qcow2HeaderSize)
+	defer os.Remove(backedPath)
+
+	name := "vol-" + utils.RandomString()
+
+	if _, err = env.pool.ImportVolume(context.Background(), name, backedPath, ImageFormatAuto); err != ErrImageBackingFile {
+		t.Errorf("unexpected error importing a qcow2 image with a backing file; got=%v, want=%v", err, ErrImageBackingFile)
+	}
+
+	if _, err = env.pool.LookupStorageVolumeByName(name); !IsNotFound(err) {
+		t.Errorf("a volume was created for an image with a backing file; got=%v", err)
+	}
+}
+
+func TestStoragePoolImportVolumeCleanUp(t *testing.T) {
+	env := newTestEnvironment(t).withStoragePool()
+	defer env.cleanUp()
+
+	if err := env.pool.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := ioutil.TempFile("", "image-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.WriteString(utils.RandomString()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	name := "vol-" + utils.RandomString()
+
+	if _, err = env.pool.ImportVolume(ctx, name, file.Name(), ImageFormatRaw); err != context.Canceled {
+		t.Errorf("unexpected error importing an image with a cancelled context; got=%v, want=%v", err, context.Canceled)
+	}
+
+	// the volume is created before the upload fails, so it must be deleted
+	if _, err = env.pool.LookupStorageVolumeByName(name); !IsNotFound(err) {
+		t.Errorf("the volume was not deleted after a failed import; got=%v", err)
+	}
+}
+
+func TestStoragePoolGuard(t *testing.T) {
+	env := newTestEnvironment(t).withStoragePool()
+	defer env.cleanUp()
+
+	if err := env.pool.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	capacity, err := env.pool.InfoCapacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ratio := range []float64{0, -1} {
+		if _, err = NewStoragePoolGuard(*env.pool, ratio); err != ErrInvalidOvercommitRatio {
+			t.Errorf("unexpected error creating a guard with ratio %v; got=%v, want=%v", ratio, err, ErrInvalidOvercommitRatio)
+		}
+	}
+
+	guard, err := NewStoragePoolGuard(*env.pool, 1.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bigXML := fmt.Sprintf("<volume><name>big-%v</name><capacity unit='KiB'>%v</capacity></volume>", utils.RandomString(), 2*capacity/1024)
+
+	_, err = guard.CreateStorageVolume(bigXML, VolCreateDefault)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("unexpected error creating a volume over quota; got=%v, want=%v", err, ErrQuotaExceeded)
+	}
+
+	if quotaErr := err.(*QuotaError); quotaErr.Shortfall() == 0 || quotaErr.Pool != env.poolData.Name {
+		t.Errorf("unexpected quota error; got=%+v", quotaErr)
+	}
+
+	hugeXML := fmt.Sprintf("<volume><name>huge-%v</name><capacity unit='EiB'>16</capacity></volume>", utils.RandomString())
+
+	if _, err = guard.CreateStorageVolume(hugeXML, VolCreateDefault); err != ErrCapacityOverflow {
+		t.Errorf("unexpected error creating a volume whose capacity overflows; got=%v, want=%v", err, ErrCapacityOverflow)
+	}
+
+	imagePath := writeQcow2Header(t, 2*capacity, 0)
+	defer os.Remove(imagePath)
+
+	imageName := "vol-" + utils.RandomString()
+
+	if _, err = guard.ImportVolume(context.Background(), imageName, imagePath, ImageFormatAuto); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("unexpected error importing an image over quota; got=%v, want=%v", err, ErrQuotaExceeded)
+	}
+
+	if _, err = env.pool.LookupStorageVolumeByName(imageName); !IsNotFound(err) {
+		t.Errorf("a volume was created for an image over quota; got=%v", err)
+	}
+
+	smallXML := fmt.Sprintf("<volume><name>small-%v</name><capacity unit='KiB'>1</capacity></volume>", utils.RandomString())
+
+	vol, err := guard.CreateStorageVolume(smallXML, VolCreateDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vol.Free()
+	defer vol.Delete()
+
+	if err = guard.ResizeStorageVolume(vol, 2*capacity, VolResizeDefault); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("unexpected error resizing a volume over quota; got=%v, want=%v", err, ErrQuotaExceeded)
+	}
+
+	usage, err := env.pool.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.VolumeCapacity != 1024 {
+		t.Errorf("unexpected committed volume capacity; got=%v, want=%v", usage.VolumeCapacity, 1024)
+	}
+
+	zeroGuard := StoragePoolGuard{Pool: *env.pool}
+
+	if err = zeroGuard.Check(1); err != ErrInvalidOvercommitRatio {
+		t.Errorf("unexpected error checking a guard without ratio; got=%v, want=%v", err, ErrInvalidOvercommitRatio)
+	}
+}
+
 func BenchmarkStoragePoolBuild(b *testing.B) {
 	env := newTestEnvironment(b).withStoragePool()
 	defer env.cleanUp()
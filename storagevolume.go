@@ -4,7 +4,10 @@ package libvirt
 // #include <libvirt/libvirt.h>
 import "C"
 import (
-	"log"
+	"context"
+	"io"
+	"os"
+	"runtime"
 	"unicode/utf8"
 	"unsafe"
 )
@@ -51,20 +54,32 @@ const (
 
 // StorageVolume holds a libvirt storage volume. There are no exported fields.
 type StorageVolume struct {
-	log           *log.Logger
+	log           *logger
+	ref           *objectRef
 	virStorageVol C.virStorageVolPtr
 }
 
 // Free releases the storage volume handle. The underlying storage volume
 // continues to exist.
 func (vol StorageVolume) Free() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
+	if !vol.ref.release() {
+		vol.log.Println("storage volume object already freed")
+		return nil
+	}
+
 	vol.log.Println("freeing storage volume object...")
 	cRet := C.virStorageVolFree(vol.virStorageVol)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -75,13 +90,19 @@ func (vol StorageVolume) Free() error {
 
 // Delete deletes the storage volume from the pool.
 func (vol StorageVolume) Delete() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Println("deleting storage volume...")
 	cRet := C.virStorageVolDelete(vol.virStorageVol, 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -93,12 +114,18 @@ func (vol StorageVolume) Delete() error {
 // Key fetches the storage volume key. This is globally unique, so the same
 // volume will have the same key no matter what host it is accessed from.
 func (vol StorageVolume) Key() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Println("reading storage volume key...")
 	cKey := C.virStorageVolGetKey(vol.virStorageVol)
 
 	if cKey == nil {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -111,12 +138,18 @@ func (vol StorageVolume) Key() (string, error) {
 // Name fetches the storage volume name. This is unique within the scope of
 // a pool.
 func (vol StorageVolume) Name() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Println("reading storage volume name...")
 	cName := C.virStorageVolGetName(vol.virStorageVol)
 
 	if cName == nil {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -131,12 +164,18 @@ func (vol StorageVolume) Name() (string, error) {
 // startup. Consult pool documentation for information on getting the
 // persistent naming.
 func (vol StorageVolume) Path() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Println("reading storage volume path...")
 	cPath := C.virStorageVolGetPath(vol.virStorageVol)
 
 	if cPath == nil {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 	defer C.free(unsafe.Pointer(cPath))
@@ -149,12 +188,18 @@ func (vol StorageVolume) Path() (string, error) {
 
 // XML fetches an XML document describing all aspects of the storage volume.
 func (vol StorageVolume) XML() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Println("reading storage volume XML...")
 	cXML := C.virStorageVolGetXMLDesc(vol.virStorageVol, 0)
 
 	if cXML == nil {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return "", err
 	}
 
@@ -167,6 +212,12 @@ func (vol StorageVolume) XML() (string, error) {
 // InfoType fetches volatile information about the storage volume:
 // current type.
 func (vol StorageVolume) InfoType() (StorageVolumeType, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virStorageVolInfo
 
 	vol.log.Println("reading storage volume type...")
@@ -175,7 +226,7 @@ func (vol StorageVolume) InfoType() (StorageVolumeType, error) {
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -188,6 +239,12 @@ func (vol StorageVolume) InfoType() (StorageVolumeType, error) {
 // InfoCapacity fetches volatile information about the storage volume:
 // current capacity.
 func (vol StorageVolume) InfoCapacity() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virStorageVolInfo
 
 	vol.log.Println("reading storage volume capacity...")
@@ -196,7 +253,7 @@ func (vol StorageVolume) InfoCapacity() (uint64, error) {
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -209,6 +266,12 @@ func (vol StorageVolume) InfoCapacity() (uint64, error) {
 // InfoAllocation fetches volatile information about the storage volume:
 // current allocation.
 func (vol StorageVolume) InfoAllocation() (uint64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	var cInfo C.virStorageVolInfo
 
 	vol.log.Println("reading storage volume allocation...")
@@ -217,7 +280,7 @@ func (vol StorageVolume) InfoAllocation() (uint64, error) {
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -249,13 +312,19 @@ func (vol StorageVolume) InfoAllocation() (uint64, error) {
 // "capacity" represents the absolute new size regardless of whether it is
 // larger or smaller than the current size.
 func (vol StorageVolume) Resize(capacity uint64, flags StorageVolumeResizeFlag) error {
-	vol.log.Printf("resizing storage volume to %v bytes (flags = %v)...\n", capacity, flags)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
+	vol.log.with(Field{FieldFlags, flags}).Printf("resizing storage volume to %v bytes...\n", capacity)
 	cRet := C.virStorageVolResize(vol.virStorageVol, C.ulonglong(capacity), C.uint(flags))
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -266,13 +335,19 @@ func (vol StorageVolume) Resize(capacity uint64, flags StorageVolumeResizeFlag)
 
 // Wipe ensure data previously on a volume is not accessible to future reads.
 func (vol StorageVolume) Wipe(alg StorageVolumeWipeAlgorithm) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Printf("wiping storage volume with algorithm %v...\n", alg)
 	cRet := C.virStorageVolWipePattern(vol.virStorageVol, C.uint(alg), 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -290,16 +365,23 @@ func (vol StorageVolume) Wipe(alg StorageVolumeWipeAlgorithm) error {
 // all threads have finished using it. ie, each new thread using a vol would
 // increment the reference count.
 func (vol StorageVolume) Ref() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Println("incrementing storage volume's reference count...")
 	cRet := C.virStorageVolRef(vol.virStorageVol)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
+	vol.ref.acquire()
 	vol.log.Println("reference count incremented")
 
 	return nil
@@ -309,20 +391,27 @@ func (vol StorageVolume) Ref() error {
 // "Free" should be used to free the resources after the storage pool object is
 // no longer needed.
 func (vol StorageVolume) StoragePool() (StoragePool, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Println("looking up storage pool by storage volume...")
 	cPool := C.virStoragePoolLookupByVolume(vol.virStorageVol)
 
 	if cPool == nil {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return StoragePool{}, err
 	}
 
 	vol.log.Println("pool found")
 
 	pool := StoragePool{
-		log:            vol.log,
+		log:            vol.log.with(Field{FieldObject, objectStoragePool}),
 		virStoragePool: cPool,
+		ref:            newStoragePoolRef(cPool),
 	}
 
 	return pool, nil
@@ -343,13 +432,19 @@ func (vol StorageVolume) StoragePool() (StoragePool, error) {
 // characteristics from the source stream such as format type, capacity,
 // and allocation.
 func (vol StorageVolume) Upload(str Stream, offset uint64, length uint64) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Printf("setting up to upload %v bytes of data to storage volume in offset %v...\n", length, offset)
 	cRet := C.virStorageVolUpload(vol.virStorageVol, str.virStream, C.ulonglong(offset), C.ulonglong(length), 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -366,13 +461,68 @@ func (vol StorageVolume) Upload(str Stream, offset uint64, length uint64) error
 // successfully transferred, and detect any errors. The results will be
 // unpredictable if another active stream is writing to the storage volume.
 func (vol StorageVolume) Download(str Stream, offset uint64, length uint64) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
 	vol.log.Printf("setting up to download %v bytes of data from storage volume in offset %v...\n", length, offset)
 	cRet := C.virStorageVolDownload(vol.virStorageVol, str.virStream, C.ulonglong(offset), C.ulonglong(length), 0)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		vol.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
+		return err
+	}
+
+	vol.log.Println("data set up")
+
+	return nil
+}
+
+// UploadSparse works like Upload, but sets up a sparse stream, so holes can be
+// sent with SendHole (or SparseSendAll) instead of being transferred as zeroes.
+func (vol StorageVolume) UploadSparse(str Stream, offset uint64, length uint64) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
+	vol.log.Printf("setting up to upload %v bytes of sparse data to storage volume in offset %v...\n", length, offset)
+	cRet := C.virStorageVolUpload(vol.virStorageVol, str.virStream, C.ulonglong(offset), C.ulonglong(length), C.VIR_STORAGE_VOL_UPLOAD_SPARSE_STREAM)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	vol.log.Println("data set up")
+
+	return nil
+}
+
+// DownloadSparse works like Download, but sets up a sparse stream, so holes
+// can be received with RecvHole (or SparseRecvAll) instead of being
+// transferred as zeroes.
+func (vol StorageVolume) DownloadSparse(str Stream, offset uint64, length uint64) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(vol.ref)
+	call := vol.log.startCall()
+	defer call.finish()
+
+	vol.log.Printf("setting up to download %v bytes of sparse data from storage volume in offset %v...\n", length, offset)
+	cRet := C.virStorageVolDownload(vol.virStorageVol, str.virStream, C.ulonglong(offset), C.ulonglong(length), C.VIR_STORAGE_VOL_DOWNLOAD_SPARSE_STREAM)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
 		return err
 	}
 
@@ -380,3 +530,217 @@ func (vol StorageVolume) Download(str Stream, offset uint64, length uint64) erro
 
 	return nil
 }
+
+// DeleteContext works like Delete, but returns ctx.Err() as soon as "ctx" is
+// done, even if the volume hasn't been deleted yet.
+func (vol StorageVolume) DeleteContext(ctx context.Context) error {
+	return runContext(ctx, vol, func() error {
+		return vol.Delete()
+	}, nil)
+}
+
+// ResizeContext works like Resize, but returns ctx.Err() as soon as "ctx" is
+// done, even if the volume hasn't been resized yet.
+func (vol StorageVolume) ResizeContext(ctx context.Context, capacity uint64, flags StorageVolumeResizeFlag) error {
+	return runContext(ctx, vol, func() error {
+		return vol.Resize(capacity, flags)
+	}, nil)
+}
+
+// WipeContext works like Wipe, but returns ctx.Err() as soon as "ctx" is done.
+// libvirt can't abort a wipe in progress, so it keeps running in the
+// background until it finishes.
+func (vol StorageVolume) WipeContext(ctx context.Context, alg StorageVolumeWipeAlgorithm) error {
+	return runContext(ctx, vol, func() error {
+		return vol.Wipe(alg)
+	}, nil)
+}
+
+// sparseUnsupportedError wraps the error returned when a sparse transfer
+// isn't supported by the volume, so UploadFrom and DownloadTo can fall back to
+// a plain one.
+type sparseUnsupportedError struct {
+	err error
+}
+
+func (e sparseUnsupportedError) Error() string {
+	return e.err.Error()
+}
+
+// sparseSetupFailed returns the error to be returned when a sparse transfer
+// can't be set up: "err" is wrapped in sparseUnsupportedError if libvirt
+// reports that the operation isn't supported, or returned as is otherwise.
+func sparseSetupFailed(err error) error {
+	switch errorCode(err) {
+	case ErrNoSupport, ErrOperationUnsupported:
+		return sparseUnsupportedError{err}
+	default:
+		return err
+	}
+}
+
+// TransferProgressFunc is called by UploadFrom and DownloadTo every time more
+// data is transferred, with the total number of bytes transferred so far.
+type TransferProgressFunc func(transferred uint64)
+
+// progressReader calls "progress" after every read from "r".
+type progressReader struct {
+	r           io.Reader
+	progress    TransferProgressFunc
+	transferred uint64
+}
+
+func (pr *progressReader) Read(data []byte) (int, error) {
+	n, err := pr.r.Read(data)
+	if n > 0 {
+		pr.transferred += uint64(n)
+		pr.progress(pr.transferred)
+	}
+
+	return n, err
+}
+
+// progressWriter calls "progress" after every write to "w".
+type progressWriter struct {
+	w           io.Writer
+	progress    TransferProgressFunc
+	transferred uint64
+}
+
+func (pw *progressWriter) Write(data []byte) (int, error) {
+	n, err := pw.w.Write(data)
+	if n > 0 {
+		pw.transferred += uint64(n)
+		pw.progress(pw.transferred)
+	}
+
+	return n, err
+}
+
+// UploadFrom uploads the data read from "r" to the volume, starting at
+// "offset", until "r" reaches end-of-file. If "length" is non-zero, an error
+// is returned if "r" provides more than "length" bytes of data. "progress", if
+// not nil, is called as the data is uploaded.
+// Unlike Upload, it manages the whole stream lifecycle: the stream is finished
+// if all data is uploaded, or aborted if anything fails or "ctx" is done. It
+// never returns while "r" is still being read, so a blocked read delays the
+// return even after "ctx" is done.
+// If "r" is a regular file, a sparse stream is used and its holes (as reported
+// by SEEK_DATA and SEEK_HOLE, on Linux) are sent without reading them. If the
+// volume doesn't support sparse streams, a plain upload is made instead.
+func (vol StorageVolume) UploadFrom(ctx context.Context, r io.Reader, offset uint64, length uint64, progress TransferProgressFunc) error {
+	if f, ok := r.(*os.File); ok {
+		if sf := newSparseFile(f, progress); sf != nil {
+			err := vol.transfer(ctx, func(str Stream) error {
+				if err := vol.UploadSparse(str, offset, length); err != nil {
+					return sparseSetupFailed(err)
+				}
+
+				return str.SparseSendAll(sf.Read, sf.Section, sf.Skip)
+			})
+			if _, ok := err.(sparseUnsupportedError); !ok {
+				return err
+			}
+
+			vol.log.Println("sparse upload isn't supported; falling back to a plain upload")
+		}
+	}
+
+	if progress != nil {
+		r = &progressReader{r: r, progress: progress}
+	}
+
+	return vol.transfer(ctx, func(str Stream) error {
+		if err := vol.Upload(str, offset, length); err != nil {
+			return err
+		}
+
+		_, err := str.ReadFrom(r)
+		return err
+	})
+}
+
+// DownloadTo downloads the content of the volume, starting at "offset", and
+// writes it to "w". If "length" is zero, then the remaining contents of the
+// volume after "offset" will be downloaded. "progress", if not nil, is called
+// as the data is downloaded.
+// Unlike Download, it manages the whole stream lifecycle: the stream is
+// finished if all data is downloaded, or aborted if anything fails or "ctx" is
+// done. It never returns while "w" is still being written, so a blocked write
+// delays the return even after "ctx" is done.
+// If "w" is a regular file, a sparse stream is used and the holes in the
+// volume are skipped in the file instead of being written as zeroes; any data
+// already in the file where a hole lands is punched out (or overwritten with
+// zeroes, where that's not possible). If the volume doesn't support sparse
+// streams, a plain download is made instead.
+func (vol StorageVolume) DownloadTo(ctx context.Context, w io.Writer, offset uint64, length uint64, progress TransferProgressFunc) error {
+	if f, ok := w.(*os.File); ok {
+		if sf := newSparseFile(f, progress); sf != nil {
+			err := vol.transfer(ctx, func(str Stream) error {
+				if err := vol.DownloadSparse(str, offset, length); err != nil {
+					return sparseSetupFailed(err)
+				}
+
+				if err := str.SparseRecvAll(sf.Write, sf.Hole); err != nil {
+					return err
+				}
+
+				return sf.Close()
+			})
+			if _, ok := err.(sparseUnsupportedError); !ok {
+				return err
+			}
+
+			vol.log.Println("sparse download isn't supported; falling back to a plain download")
+		}
+	}
+
+	if progress != nil {
+		w = &progressWriter{w: w, progress: progress}
+	}
+
+	return vol.transfer(ctx, func(str Stream) error {
+		if err := vol.Download(str, offset, length); err != nil {
+			return err
+		}
+
+		_, err := str.WriteTo(w)
+		return err
+	})
+}
+
+// transfer creates a new stream and runs "op" with it, finishing the stream
+// if "op" succeeds or aborting it otherwise. If "ctx" is done first, the stream
+// is aborted and ctx.Err() is returned, but only after "op" returns, so it
+// doesn't use the caller's reader or writer afterwards. Nothing is done if
+// "ctx" is already done.
+func (vol StorageVolume) transfer(ctx context.Context, op func(str Stream) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	str, err := vol.connection().NewStream(StrDefault)
+	if err != nil {
+		return err
+	}
+	defer str.Free()
+
+	return runContextWait(ctx, str, func() error {
+		if err := op(str); err != nil {
+			str.Abort()
+			return err
+		}
+
+		return str.Finish()
+	}, str.Abort)
+}
+
+// connection returns the connection which owns the storage volume. It doesn't
+// hold a new reference, so it must not be closed nor used after the volume is
+// freed.
+func (vol StorageVolume) connection() Connection {
+	return Connection{
+		log:        vol.log.with(Field{FieldObject, objectConnection}),
+		virConnect: C.virStorageVolGetConnect(vol.virStorageVol),
+	}
+}
@@ -0,0 +1,92 @@
+package libvirt
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+// maxBackingChainLength limits how deep BackingChain walks, in case the
+// backing files form a cycle.
+const maxBackingChainLength = 64
+
+// ErrBackingChainTooLong is returned by BackingChain when the chain is longer
+// than expected, which probably means the backing files form a cycle.
+var ErrBackingChainTooLong = errors.New("backing chain is too long")
+
+// CreateLinkedClone creates a qcow2 volume called "name" in the pool, whose
+// backing store is the volume "base", so only the data written to the clone is
+// stored in it. "format" is the format of "base"; if it's ImageFormatAuto, it's
+// read from the description of "base". The clone has the same capacity as
+// "base", which must not be modified while the clone exists.
+// "Free" should be used to free the resources after the storage volume object
+// is no longer needed.
+func (pool StoragePool) CreateLinkedClone(name string, base StorageVolume, format ImageFormat) (StorageVolume, error) {
+	baseDesc, err := base.desc()
+	if err != nil {
+		return StorageVolume{}, err
+	}
+
+	if format == ImageFormatAuto && baseDesc.Target.Format != nil {
+		format = ImageFormat(baseDesc.Target.Format.Type)
+	}
+
+	desc := newVolumeXML(name, baseDesc.Capacity.Value, string(ImageFormatQcow2))
+	desc.BackingStore = &volumeTargetXML{
+		Path:   baseDesc.Target.Path,
+		Format: &volumeFormatXML{Type: string(format)},
+	}
+
+	volXML, err := xml.Marshal(desc)
+	if err != nil {
+		return StorageVolume{}, err
+	}
+
+	pool.log.Printf("creating linked clone %v of %v...\n", name, baseDesc.Target.Path)
+
+	return pool.CreateStorageVolume(string(volXML), VolCreateDefault)
+}
+
+// BackingChain returns the volumes underlying the volume, following its
+// backing store: the first one is the backing store of the volume, the second
+// one is the backing store of the first one, and so on. Every backing file
+// must belong to a storage pool. The returned volumes must be freed by the
+// caller.
+func (vol StorageVolume) BackingChain() ([]StorageVolume, error) {
+	desc, err := vol.desc()
+	if err != nil {
+		return nil, err
+	}
+
+	conn := vol.connection()
+	seen := map[string]bool{desc.Target.Path: true}
+	var chain []StorageVolume
+
+	fail := func(err error) ([]StorageVolume, error) {
+		for _, v := range chain {
+			v.Free()
+		}
+
+		return nil, err
+	}
+
+	for desc.BackingStore != nil && desc.BackingStore.Path != "" {
+		path := desc.BackingStore.Path
+
+		if seen[path] || len(chain) == maxBackingChainLength {
+			return fail(ErrBackingChainTooLong)
+		}
+		seen[path] = true
+
+		backing, err := conn.LookupStorageVolumeByPath(path)
+		if err != nil {
+			return fail(err)
+		}
+		chain = append(chain, backing)
+
+		if desc, err = backing.desc(); err != nil {
+			return fail(err)
+		}
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,216 @@
+package libvirt
+
+import (
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+)
+
+// VolumeEncryptionFormat defines how an encrypted storage volume is stored.
+type VolumeEncryptionFormat uint32
+
+// Possible values for VolumeEncryptionFormat.
+const (
+	VolEncryptionLUKS      VolumeEncryptionFormat = iota // a raw LUKS volume
+	VolEncryptionQcow2LUKS                               // a qcow2 volume with LUKS encrypted data
+)
+
+// DefaultEncryptionKeySize is the size, in bytes, of the passphrase generated
+// for an encrypted storage volume when no other value is specified.
+const DefaultEncryptionKeySize = 32
+
+// ErrInvalidEncryptedVolume is returned when creating an encrypted storage
+// volume with invalid options.
+var ErrInvalidEncryptedVolume = errors.New("invalid encrypted storage volume options")
+
+// ErrVolumeNotEncrypted is returned by "DeleteEncrypted" when the storage
+// volume doesn't reference an encryption secret.
+var ErrVolumeNotEncrypted = errors.New("storage volume is not encrypted")
+
+// EncryptedVolumeOptions describes an encrypted storage volume to be created by
+// CreateEncryptedStorageVolume.
+type EncryptedVolumeOptions struct {
+	Name     string                 // the volume name
+	Capacity uint64                 // the volume capacity, in bytes
+	Format   VolumeEncryptionFormat // how the volume is stored
+	KeySize  int                    // the passphrase size, in bytes; 0 means DefaultEncryptionKeySize
+}
+
+// volumeEncryptionXML is the "encryption" element of a volume or a domain disk.
+type volumeEncryptionXML struct {
+	XMLName xml.Name `xml:"encryption"`
+	Format  string   `xml:"format,attr"`
+	Secret  struct {
+		Type string `xml:"type,attr"`
+		UUID string `xml:"uuid,attr"`
+	} `xml:"secret"`
+}
+
+func newVolumeEncryptionXML(secretUUID string) volumeEncryptionXML {
+	enc := volumeEncryptionXML{Format: "luks"}
+	enc.Secret.Type = "passphrase"
+	enc.Secret.UUID = secretUUID
+
+	return enc
+}
+
+// encryptionSecretXML is the XML description of the secret of an encrypted
+// volume.
+type encryptionSecretXML struct {
+	XMLName     xml.Name `xml:"secret"`
+	Ephemeral   string   `xml:"ephemeral,attr"`
+	Private     string   `xml:"private,attr"`
+	Description string   `xml:"description"`
+}
+
+// CreateEncryptedStorageVolume creates a LUKS encrypted storage volume in the
+// pool. A random passphrase is generated and stored in a new private secret,
+// which is referenced by the volume; the passphrase itself is never returned
+// nor logged. Both the volume and the secret must be freed by the caller. Use
+// DiskEncryptionXML to attach the volume to a domain, and DeleteEncrypted to
+// delete both of them.
+func (pool StoragePool) CreateEncryptedStorageVolume(opts EncryptedVolumeOptions) (StorageVolume, Secret, error) {
+	if opts.Name == "" || opts.KeySize < 0 {
+		return StorageVolume{}, Secret{}, ErrInvalidEncryptedVolume
+	}
+
+	var formatType string
+	switch opts.Format {
+	case VolEncryptionLUKS:
+		formatType = "raw"
+	case VolEncryptionQcow2LUKS:
+		formatType = "qcow2"
+	default:
+		return StorageVolume{}, Secret{}, ErrInvalidEncryptedVolume
+	}
+
+	keySize := opts.KeySize
+	if keySize == 0 {
+		keySize = DefaultEncryptionKeySize
+	}
+
+	key := make([]byte, keySize)
+	defer zeroBytes(key)
+
+	if _, err := rand.Read(key); err != nil {
+		return StorageVolume{}, Secret{}, err
+	}
+
+	secretXML, err := xml.Marshal(encryptionSecretXML{
+		Ephemeral:   "no",
+		Private:     "yes",
+		Description: "passphrase of storage volume " + opts.Name,
+	})
+	if err != nil {
+		return StorageVolume{}, Secret{}, err
+	}
+
+	conn := pool.connection()
+
+	pool.log.Printf("creating encrypted storage volume %v...\n", opts.Name)
+
+	sec, err := conn.DefineSecret(string(secretXML))
+	if err != nil {
+		return StorageVolume{}, Secret{}, err
+	}
+
+	// the secret must not be left behind if anything fails from now on
+	fail := func(err error) (StorageVolume, Secret, error) {
+		sec.Undefine()
+		sec.Free()
+		return StorageVolume{}, Secret{}, err
+	}
+
+	if err = sec.SetValueBytes(key); err != nil {
+		return fail(err)
+	}
+
+	secretUUID, err := sec.UUID()
+	if err != nil {
+		return fail(err)
+	}
+
+	volDesc := newVolumeXML(opts.Name, opts.Capacity, formatType)
+	enc := newVolumeEncryptionXML(secretUUID)
+	volDesc.Target.Encryption = &enc
+
+	volXML, err := xml.Marshal(volDesc)
+	if err != nil {
+		return fail(err)
+	}
+
+	vol, err := pool.CreateStorageVolume(string(volXML), VolCreateDefault)
+	if err != nil {
+		return fail(err)
+	}
+
+	pool.log.Printf("encrypted storage volume created (secret = %v)\n", secretUUID)
+
+	return vol, sec, nil
+}
+
+// DiskEncryptionXML provides the "encryption" element which must be added to
+// a domain disk to use an encrypted storage volume whose passphrase is stored
+// in the secret.
+func (sec Secret) DiskEncryptionXML() (string, error) {
+	uuid, err := sec.UUID()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := xml.Marshal(newVolumeEncryptionXML(uuid))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// EncryptionSecretUUID provides the UUID of the secret which holds the
+// passphrase of the volume. It returns an empty string if the volume isn't
+// encrypted.
+func (vol StorageVolume) EncryptionSecretUUID() (string, error) {
+	desc, err := vol.desc()
+	if err != nil {
+		return "", err
+	}
+
+	if desc.Target.Encryption == nil {
+		return "", nil
+	}
+
+	return desc.Target.Encryption.Secret.UUID, nil
+}
+
+// DeleteEncrypted deletes an encrypted storage volume. If "undefineSecret" is
+// true, the secret which holds its passphrase is undefined as well, after the
+// volume is deleted. ErrVolumeNotEncrypted is returned, and nothing is
+// deleted, if the volume doesn't reference a secret; nothing is deleted
+// either if the secret must be undefined but it can't be found.
+func (vol StorageVolume) DeleteEncrypted(undefineSecret bool) error {
+	secretUUID, err := vol.EncryptionSecretUUID()
+	if err != nil {
+		return err
+	}
+
+	if secretUUID == "" {
+		return ErrVolumeNotEncrypted
+	}
+
+	if !undefineSecret {
+		return vol.Delete()
+	}
+
+	// the secret is looked up first, so it's not left behind if that fails
+	sec, err := vol.connection().LookupSecretByUUID(secretUUID)
+	if err != nil {
+		return err
+	}
+	defer sec.Free()
+
+	if err = vol.Delete(); err != nil {
+		return err
+	}
+
+	return sec.Undefine()
+}
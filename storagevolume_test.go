@@ -2,7 +2,12 @@ package libvirt
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/cd1/utils-golang"
@@ -108,6 +113,26 @@ func TestStorageVolumeWipe(t *testing.T) {
 	}
 }
 
+func TestStorageVolumeContext(t *testing.T) {
+	env := newTestEnvironment(t).withStorageVolume()
+	defer env.cleanUp()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := env.vol.WipeContext(ctx, VolWipeAlgZero); err != context.Canceled {
+		t.Errorf("unexpected error when wiping a storage volume with a cancelled context; got=%v, want=%v", err, context.Canceled)
+	}
+
+	if err := env.vol.WipeContext(context.Background(), VolWipeAlgZero); err != nil {
+		t.Error(err)
+	}
+
+	if err := env.vol.ResizeContext(context.Background(), deltaResizeChunkSize, VolResizeDelta); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestStorageVolumeRef(t *testing.T) {
 	env := newTestEnvironment(t).withStorageVolume()
 	defer env.cleanUp()
@@ -198,6 +223,263 @@ func TestStorageVolumeUploadDownload(t *testing.T) {
 	}
 }
 
+func TestStorageVolumeUploadFromDownloadTo(t *testing.T) {
+	env := newTestEnvironment(t).withStorageVolume()
+	defer env.cleanUp()
+
+	data := utils.RandomString()
+	dataLen := uint64(len(data))
+
+	var uploaded uint64
+	progress := func(transferred uint64) {
+		uploaded = transferred
+	}
+
+	if err := env.vol.UploadFrom(context.Background(), strings.NewReader(data), 0, dataLen, progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if uploaded != dataLen {
+		t.Errorf("unexpected upload progress; got=%v, want=%v", uploaded, dataLen)
+	}
+
+	var buf bytes.Buffer
+
+	if err := env.vol.DownloadTo(context.Background(), &buf, 0, dataLen, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if dl := buf.String(); dl != data {
+		t.Errorf("unexpected downloaded content; got=%v, want=%v", dl, data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := env.vol.DownloadTo(ctx, &buf, 0, dataLen, nil); err != context.Canceled {
+		t.Errorf("unexpected error with a cancelled context; got=%v, want=%v", err, context.Canceled)
+	}
+}
+
+func TestStorageVolumeSparseTransfer(t *testing.T) {
+	env := newTestEnvironment(t).withStorageVolume()
+	defer env.cleanUp()
+
+	const fileSize = 128 * 1024 // 128 KiB
+
+	if err := env.vol.Resize(fileSize, VolResizeDefault); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ioutil.TempFile("", "sparse-src-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+
+	data := utils.RandomString()
+
+	// data, followed by a hole, then more data and a trailing hole
+	if _, err = src.WriteAt([]byte(data), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = src.WriteAt([]byte(data), fileSize/2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = src.Truncate(fileSize); err != nil {
+		t.Fatal(err)
+	}
+
+	var uploaded uint64
+	progress := func(transferred uint64) {
+		uploaded = transferred
+	}
+
+	if err = env.vol.UploadFrom(context.Background(), src, 0, fileSize, progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if uploaded != fileSize {
+		t.Errorf("unexpected upload progress; got=%v, want=%v", uploaded, fileSize)
+	}
+
+	dst, err := ioutil.TempFile("", "sparse-dst-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	// stale data must not survive where the volume has holes
+	if _, err = dst.Write(bytes.Repeat([]byte{0xff}, fileSize)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = dst.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = env.vol.DownloadTo(context.Background(), dst, 0, fileSize, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	srcContent, err := ioutil.ReadFile(src.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dstContent, err := ioutil.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(dstContent, srcContent) {
+		t.Errorf("unexpected downloaded content; got=%v bytes, want=%v bytes", len(dstContent), len(srcContent))
+	}
+
+	// the holes must be kept, otherwise the plain transfer was used
+	if seekHoleSupported {
+		info, err := dst.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if allocated := info.Sys().(*syscall.Stat_t).Blocks * 512; allocated >= fileSize {
+			t.Errorf("the downloaded file has no holes; got=%v bytes allocated, want<%v", allocated, fileSize)
+		}
+	}
+}
+
+func TestStorageVolumeLinkedClone(t *testing.T) {
+	env := newTestEnvironment(t).withStorageVolume()
+	defer env.cleanUp()
+
+	basePath, err := env.vol.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := env.pool.CreateLinkedClone("clone-"+utils.RandomString(), *env.vol, ImageFormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone.Free()
+	defer clone.Delete()
+
+	clonePath, err := clone.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone2, err := env.pool.CreateLinkedClone("clone-"+utils.RandomString(), clone, ImageFormatQcow2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone2.Free()
+	defer clone2.Delete()
+
+	chain, err := clone2.BackingChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, v := range chain {
+		path, err := v.Path()
+		if err != nil {
+			t.Error(err)
+		}
+
+		paths = append(paths, path)
+		v.Free()
+	}
+
+	if len(paths) != 2 || paths[0] != clonePath || paths[1] != basePath {
+		t.Errorf("unexpected backing chain; got=%v, want=%v", paths, []string{clonePath, basePath})
+	}
+
+	chain, err = env.vol.BackingChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chain) != 0 {
+		t.Errorf("unexpected backing chain length of base volume; got=%v, want=0", len(chain))
+	}
+}
+
+func TestStorageVolumeEncrypted(t *testing.T) {
+	env := newTestEnvironment(t).withStoragePool()
+	defer env.cleanUp()
+
+	if err := env.pool.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := env.pool.CreateEncryptedStorageVolume(EncryptedVolumeOptions{}); err != ErrInvalidEncryptedVolume {
+		t.Errorf("unexpected error with invalid options; got=%v, want=%v", err, ErrInvalidEncryptedVolume)
+	}
+
+	vol, sec, err := env.pool.CreateEncryptedStorageVolume(EncryptedVolumeOptions{
+		Name:     "vol-" + utils.RandomString(),
+		Capacity: 1024 * 1024,
+		Format:   VolEncryptionLUKS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vol.Free()
+	defer sec.Free()
+
+	secUUID, err := sec.UUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volSecUUID, err := vol.EncryptionSecretUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if volSecUUID != secUUID {
+		t.Errorf("unexpected volume secret UUID; got=%v, want=%v", volSecUUID, secUUID)
+	}
+
+	// the secret is private, so its value can't be read back
+	if _, err = sec.ValueBytes(); err == nil {
+		t.Error("the value of the encryption secret could be read")
+	}
+
+	secXML, err := sec.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(secXML, "private='yes'") {
+		t.Errorf("the encryption secret is not private; got=%v", secXML)
+	}
+
+	diskXML, err := sec.DiskEncryptionXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(diskXML, secUUID) {
+		t.Errorf("disk encryption XML doesn't reference the secret; got=%v, want=%v", diskXML, secUUID)
+	}
+
+	if err = vol.DeleteEncrypted(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = env.conn.LookupSecretByUUID(secUUID); !IsNotFound(err) {
+		t.Errorf("unexpected error looking up undefined secret; got=%v, want=%v", err, ErrNoSecret)
+	}
+}
+
 func BenchmarkStorageVolumeResize(b *testing.B) {
 	env := newTestEnvironment(b).withStorageVolume()
 	defer env.cleanUp()
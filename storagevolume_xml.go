@@ -0,0 +1,65 @@
+package libvirt
+
+import (
+	"encoding/xml"
+)
+
+// volumeXML holds the parts of the volume XML description which are built or
+// read by the helpers of this package (e.g. CreateEncryptedStorageVolume,
+// ImportVolume, CreateLinkedClone).
+type volumeXML struct {
+	XMLName      xml.Name          `xml:"volume"`
+	Name         string            `xml:"name,omitempty"`
+	Capacity     volumeCapacityXML `xml:"capacity"`
+	Target       volumeTargetXML   `xml:"target"`
+	BackingStore *volumeTargetXML  `xml:"backingStore"`
+}
+
+// volumeCapacityXML is the capacity of a volume, in "Unit" (bytes by default).
+type volumeCapacityXML struct {
+	Unit  string `xml:"unit,attr,omitempty"`
+	Value uint64 `xml:",chardata"`
+}
+
+// volumeTargetXML describes the "target" and "backingStore" elements of a
+// volume.
+type volumeTargetXML struct {
+	Path       string               `xml:"path,omitempty"`
+	Format     *volumeFormatXML     `xml:"format"`
+	Encryption *volumeEncryptionXML `xml:"encryption"`
+}
+
+// volumeFormatXML is the format of a volume (e.g. "raw", "qcow2").
+type volumeFormatXML struct {
+	Type string `xml:"type,attr"`
+}
+
+// newVolumeXML builds the description of a new volume called "name", with
+// "capacity" bytes, stored in "format".
+func newVolumeXML(name string, capacity uint64, format string) volumeXML {
+	return volumeXML{
+		Name:     name,
+		Capacity: volumeCapacityXML{Unit: "bytes", Value: capacity},
+		Target: volumeTargetXML{
+			Format: &volumeFormatXML{Type: format},
+		},
+	}
+}
+
+// parseVolumeXML parses a volume XML description.
+func parseVolumeXML(xmlDesc string) (volumeXML, error) {
+	var desc volumeXML
+	err := xml.Unmarshal([]byte(xmlDesc), &desc)
+
+	return desc, err
+}
+
+// desc parses the XML description of the volume.
+func (vol StorageVolume) desc() (volumeXML, error) {
+	xmlDesc, err := vol.XML()
+	if err != nil {
+		return volumeXML{}, err
+	}
+
+	return parseVolumeXML(xmlDesc)
+}
@@ -4,8 +4,9 @@ package libvirt
 // #include <libvirt/libvirt.h>
 import "C"
 import (
+	"context"
 	"io"
-	"log"
+	"runtime"
 	"unsafe"
 )
 
@@ -20,7 +21,8 @@ const (
 
 // Stream holds a libvirt stream. There are no exported fields.
 type Stream struct {
-	log       *log.Logger
+	log       *logger
+	ref       *objectRef
 	virStream C.virStreamPtr
 }
 
@@ -30,13 +32,24 @@ type Stream struct {
 // stream. If a stream needs to be disposed of prior to end of stream being
 // reached, then the Abort function should be called first.
 func (str Stream) Free() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	if !str.ref.release() {
+		str.log.Println("stream object already freed")
+		return nil
+	}
+
 	str.log.Println("freeing stream object...")
 	cRet := C.virStreamFree(str.virStream)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		str.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -51,13 +64,19 @@ func (str Stream) Free() error {
 // input streams this can be used to inform the driver that it should stop
 // sending data.
 func (str Stream) Abort() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
 	str.log.Println("aborting stream...")
 	cRet := C.virStreamAbort(str.virStream)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		str.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -74,13 +93,19 @@ func (str Stream) Abort() error {
 // this returns a success code the application can be sure that all data has
 // been successfully processed.
 func (str Stream) Finish() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
 	str.log.Println("finishing stream...")
 	cRet := C.virStreamFinish(str.virStream)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		str.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
@@ -94,16 +119,23 @@ func (str Stream) Finish() error {
 // reference count, once the caller no longer needs the reference to this
 // object.
 func (str Stream) Ref() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
 	str.log.Println("incrementing stream's reference count...")
 	cRet := C.virStreamRef(str.virStream)
 	ret := int32(cRet)
 
 	if ret == -1 {
 		err := LastError()
-		str.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return err
 	}
 
+	str.ref.acquire()
 	str.log.Println("reference count incremented")
 
 	return nil
@@ -116,9 +148,17 @@ func (str Stream) Ref() error {
 // This method may not be used if a stream source has been registered.
 // Errors are not guaranteed to be reported synchronously with the call, but may
 // instead be delayed until a subsequent call.
+// If the stream is non-blocking (see StrNonBlock), ErrWouldBlock is returned
+// when no data can be sent yet.
 // This function is equivalent to the libvirt function "Send" but it has been
 // renamed to "Write" in order to implement the standard interface io.Writer.
 func (str Stream) Write(data []byte) (int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
 	cData := C.CString(string(data))
 	defer C.free(unsafe.Pointer(cData))
 
@@ -128,9 +168,14 @@ func (str Stream) Write(data []byte) (int, error) {
 	cRet := C.virStreamSend(str.virStream, cData, C.size_t(l))
 	ret := int32(cRet)
 
+	if ret == -2 {
+		str.log.Println("stream operation would block")
+		return 0, ErrWouldBlock
+	}
+
 	if ret < 0 {
 		err := LastError()
-		str.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -143,11 +188,19 @@ func (str Stream) Write(data []byte) (int, error) {
 // calling application for an arbitrary amount of time.
 // Errors are not guaranteed to be reported synchronously with the call, but may
 // instead be delayed until a subsequent call.
+// If the stream is non-blocking (see StrNonBlock), ErrWouldBlock is returned
+// when no data is available yet.
 // This function is equivalent to the libvirt function "Recv" but it has been
 // renamed to "Read" in order to implement the standard interface io.Reader. And
 // due to that interface requirement, this function now returns (0, io.EOF)
 // instead of (0, nil) when there's nothing left to be read from the stream.
 func (str Stream) Read(data []byte) (int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
 	dataLen := len(data)
 
 	cData := (*C.char)(C.malloc(C.size_t(dataLen)))
@@ -157,9 +210,14 @@ func (str Stream) Read(data []byte) (int, error) {
 	cRet := C.virStreamRecv(str.virStream, (*C.char)(unsafe.Pointer(cData)), C.size_t(dataLen))
 	ret := int32(cRet)
 
+	if ret == -2 {
+		str.log.Println("stream operation would block")
+		return 0, ErrWouldBlock
+	}
+
 	if ret < 0 {
 		err := LastError()
-		str.log.Printf("an error occurred: %v\n", err)
+		call.error(err)
 		return 0, err
 	}
 
@@ -174,3 +232,177 @@ func (str Stream) Read(data []byte) (int, error) {
 
 	return int(ret), nil
 }
+
+// ReadContext works like Read, but aborts the stream (see Abort) as soon as
+// "ctx" is done, waits for the pending read to stop, and returns ctx.Err().
+// After that, the stream can't be used anymore.
+func (str Stream) ReadContext(ctx context.Context, data []byte) (int, error) {
+	var n int
+
+	err := runContextWait(ctx, str, func() error {
+		var err error
+		n, err = str.Read(data)
+		return err
+	}, str.Abort)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// WriteContext works like Write, but aborts the stream (see Abort) as soon as
+// "ctx" is done, waits for the pending write to stop, and returns ctx.Err().
+// After that, the stream can't be used anymore.
+func (str Stream) WriteContext(ctx context.Context, data []byte) (int, error) {
+	var n int
+
+	err := runContextWait(ctx, str, func() error {
+		var err error
+		n, err = str.Write(data)
+		return err
+	}, str.Abort)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// streamChunkSize is the size of the buffer used by ReadFrom and WriteTo to
+// transfer data through the stream.
+const streamChunkSize = 256 * 1024 // 256 KiB
+
+// send sends "n" bytes from the C buffer "cData" to the stream.
+func (str Stream) send(cData *C.char, n int) (int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	str.log.Printf("sending %v bytes to stream...\n", n)
+	cRet := C.virStreamSend(str.virStream, cData, C.size_t(n))
+	ret := int32(cRet)
+
+	if ret == -2 {
+		str.log.Println("stream operation would block")
+		return 0, ErrWouldBlock
+	}
+
+	if ret < 0 {
+		err := LastError()
+		call.error(err)
+		return 0, err
+	}
+
+	str.log.Printf("%v bytes sent\n", ret)
+
+	return int(ret), nil
+}
+
+// recv receives up to "n" bytes from the stream into the C buffer "cData".
+func (str Stream) recv(cData *C.char, n int) (int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	str.log.Printf("receiving %v bytes from stream...\n", n)
+	cRet := C.virStreamRecv(str.virStream, cData, C.size_t(n))
+	ret := int32(cRet)
+
+	if ret == -2 {
+		str.log.Println("stream operation would block")
+		return 0, ErrWouldBlock
+	}
+
+	if ret < 0 {
+		err := LastError()
+		call.error(err)
+		return 0, err
+	}
+
+	str.log.Printf("%v bytes received\n", ret)
+
+	return int(ret), nil
+}
+
+// newStreamBuffer allocates a C buffer of streamChunkSize bytes, and a Go slice
+// backed by it, so data can be moved between Go and libvirt without copying it.
+// The buffer must be released with C.free.
+func newStreamBuffer() (*C.char, []byte) {
+	cData := (*C.char)(C.malloc(C.size_t(streamChunkSize)))
+	data := (*[1 << 30]byte)(unsafe.Pointer(cData))[:streamChunkSize:streamChunkSize]
+
+	return cData, data
+}
+
+// ReadFrom writes the data read from "r" to the stream until "r" reaches
+// end-of-file or an error occurs, in order to implement the standard interface
+// io.ReaderFrom. The data is read directly into the buffer passed to libvirt,
+// so it's not copied again. It doesn't call Finish nor Abort.
+func (str Stream) ReadFrom(r io.Reader) (int64, error) {
+	cData, data := newStreamBuffer()
+	defer C.free(unsafe.Pointer(cData))
+
+	var total int64
+
+	for {
+		n, readErr := r.Read(data)
+
+		for sent := 0; sent < n; {
+			ret, err := str.send((*C.char)(unsafe.Pointer(&data[sent])), n-sent)
+			if err != nil {
+				return total, err
+			}
+
+			sent += ret
+			total += int64(ret)
+		}
+
+		if readErr == io.EOF {
+			return total, nil
+		}
+
+		if readErr != nil {
+			return total, readErr
+		}
+	}
+}
+
+// WriteTo writes the data read from the stream to "w" until the stream reaches
+// end-of-file or an error occurs, in order to implement the standard interface
+// io.WriterTo. The data is received directly into the buffer passed to "w", so
+// it's not copied again. It doesn't call Finish nor Abort.
+func (str Stream) WriteTo(w io.Writer) (int64, error) {
+	cData, data := newStreamBuffer()
+	defer C.free(unsafe.Pointer(cData))
+
+	var total int64
+
+	for {
+		n, err := str.recv(cData, len(data))
+		if err != nil {
+			return total, err
+		}
+
+		if n == 0 {
+			return total, nil
+		}
+
+		written, err := w.Write(data[:n])
+		total += int64(written)
+
+		if err != nil {
+			return total, err
+		}
+
+		if written != n {
+			return total, io.ErrShortWrite
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+
+int virStreamEventAddCallbackWrapper(virStreamPtr stream, int events, long callbackID);
+*/
+import "C"
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+)
+
+// StreamEventType describes the I/O events of a non-blocking stream.
+type StreamEventType uint32
+
+// Possible values for StreamEventType. They may be combined.
+const (
+	StrEventReadable StreamEventType = C.VIR_STREAM_EVENT_READABLE
+	StrEventWritable StreamEventType = C.VIR_STREAM_EVENT_WRITABLE
+	StrEventError    StreamEventType = C.VIR_STREAM_EVENT_ERROR
+	StrEventHangup   StreamEventType = C.VIR_STREAM_EVENT_HANGUP
+)
+
+// ErrWouldBlock is returned by the I/O methods of a non-blocking stream (see
+// StrNonBlock) when the operation can't be completed without blocking. The
+// operation should be retried after the stream becomes readable or writable.
+var ErrWouldBlock = errors.New("stream operation would block")
+
+// StreamEventCallback is called when an I/O event happens on a non-blocking
+// stream. It's called from the event loop, so it should return quickly.
+type StreamEventCallback func(str Stream, events StreamEventType)
+
+// streamEventHandler holds the callback registered with AddEventCallback, and
+// a function to be called when libvirt releases it.
+type streamEventHandler struct {
+	str      Stream
+	callback StreamEventCallback
+	done     func()
+}
+
+func (h *streamEventHandler) free() {
+	if h.done != nil {
+		h.done()
+	}
+}
+
+// AddEventCallback registers a callback to be notified when the non-blocking
+// stream becomes readable or writable, or when an error or hangup happens,
+// according to "events". Only one callback may be registered on a stream at a
+// time.
+// Events are only delivered while the event loop is running (see
+// StartEventLoop).
+func (str Stream) AddEventCallback(events StreamEventType, callback StreamEventCallback) error {
+	return str.addEventCallback(events, &streamEventHandler{
+		str:      str,
+		callback: callback,
+	})
+}
+
+func (str Stream) addEventCallback(events StreamEventType, handler *streamEventHandler) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	callbackID := registerCallback(handler)
+
+	str.log.Printf("adding stream event callback (events = %v)...\n", events)
+	cRet := C.virStreamEventAddCallbackWrapper(str.virStream, C.int(events), C.long(callbackID))
+	ret := int32(cRet)
+
+	if ret == -1 {
+		unregisterCallback(callbackID)
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	str.log.Println("stream event callback added")
+
+	return nil
+}
+
+// UpdateEventCallback changes the events which are notified to the callback
+// registered with AddEventCallback. Passing 0 pauses the notifications.
+func (str Stream) UpdateEventCallback(events StreamEventType) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	str.log.Printf("updating stream event callback (events = %v)...\n", events)
+	cRet := C.virStreamEventUpdateCallback(str.virStream, C.int(events))
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	str.log.Println("stream event callback updated")
+
+	return nil
+}
+
+// RemoveEventCallback removes the callback registered with AddEventCallback.
+func (str Stream) RemoveEventCallback() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	str.log.Println("removing stream event callback...")
+	cRet := C.virStreamEventRemoveCallback(str.virStream)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	str.log.Println("stream event callback removed")
+
+	return nil
+}
+
+//export streamEventCallback
+func streamEventCallback(cStream C.virStreamPtr, cEvents C.int, cCallbackID C.long) {
+	handler, ok := lookupCallback(int(cCallbackID)).(*streamEventHandler)
+	if !ok {
+		return
+	}
+
+	handler.callback(handler.str, StreamEventType(cEvents))
+}
+
+// NonBlockingStream adapts a non-blocking stream to goroutines: its Read and
+// Write methods wait for the stream to become ready, or for a context to be
+// done, instead of returning ErrWouldBlock. It must be closed when no longer
+// needed; the stream itself must still be finished and freed by the caller.
+type NonBlockingStream struct {
+	str    Stream
+	events chan StreamEventType
+}
+
+// NonBlocking registers an event callback on the stream, which must have been
+// created with StrNonBlock, and returns an adapter to wait for its events.
+// The event loop must be running (see StartEventLoop).
+func (str Stream) NonBlocking() (*NonBlockingStream, error) {
+	nb := &NonBlockingStream{
+		str:    str,
+		events: make(chan StreamEventType, 1),
+	}
+
+	handler := &streamEventHandler{
+		str: str,
+		callback: func(_ Stream, events StreamEventType) {
+			select {
+			case nb.events <- events:
+			default:
+			}
+		},
+		done: func() {
+			close(nb.events)
+		},
+	}
+
+	// no events are wanted until someone waits for them
+	if err := str.addEventCallback(0, handler); err != nil {
+		return nil, err
+	}
+
+	return nb, nil
+}
+
+// Events returns the channel which receives the events of the stream. The
+// events which are delivered depend on the method waiting for them, so it's
+// mostly useful in a "select" after calling Wait.
+func (nb *NonBlockingStream) Events() <-chan StreamEventType {
+	return nb.events
+}
+
+// Wait waits until one of "events" (or an error or hangup) happens on the
+// stream, or until "ctx" is done.
+func (nb *NonBlockingStream) Wait(ctx context.Context, events StreamEventType) (StreamEventType, error) {
+	if err := nb.str.UpdateEventCallback(events | StrEventError | StrEventHangup); err != nil {
+		return 0, err
+	}
+	defer nb.str.UpdateEventCallback(0)
+
+	select {
+	case ev, ok := <-nb.events:
+		if !ok {
+			return 0, io.ErrClosedPipe
+		}
+
+		return ev, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Read works like Stream.Read, but waits for the stream to become readable
+// instead of returning ErrWouldBlock, until "ctx" is done.
+func (nb *NonBlockingStream) Read(ctx context.Context, data []byte) (int, error) {
+	for {
+		n, err := nb.str.Read(data)
+		if err != ErrWouldBlock {
+			return n, err
+		}
+
+		if _, err = nb.Wait(ctx, StrEventReadable); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Write works like Stream.Write, but waits for the stream to become writable
+// instead of returning ErrWouldBlock, until "ctx" is done.
+func (nb *NonBlockingStream) Write(ctx context.Context, data []byte) (int, error) {
+	for {
+		n, err := nb.str.Write(data)
+		if err != ErrWouldBlock {
+			return n, err
+		}
+
+		if _, err = nb.Wait(ctx, StrEventWritable); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Close removes the event callback from the stream. The events channel is
+// closed afterwards.
+func (nb *NonBlockingStream) Close() error {
+	return nb.str.RemoveEventCallback()
+}
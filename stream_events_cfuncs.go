@@ -0,0 +1,17 @@
+package libvirt
+
+/*
+#include <libvirt/libvirt.h>
+
+void streamEventCallback(virStreamPtr stream, int events, long callbackID);
+void freeCallbackHelper(void *opaque);
+
+static void streamEventCallbackHelper(virStreamPtr stream, int events, void *opaque) {
+    streamEventCallback(stream, events, (long)opaque);
+}
+
+int virStreamEventAddCallbackWrapper(virStreamPtr stream, int events, long callbackID) {
+    return virStreamEventAddCallback(stream, events, streamEventCallbackHelper, (void *)callbackID, freeCallbackHelper);
+}
+*/
+import "C"
@@ -0,0 +1,444 @@
+package libvirt
+
+// #include <stdlib.h>
+// #include <libvirt/libvirt.h>
+import "C"
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"unsafe"
+)
+
+// StreamRecvFlag defines how data should be received from a stream.
+type StreamRecvFlag uint32
+
+// Possible values for StreamRecvFlag.
+const (
+	StrRecvDefault    StreamRecvFlag = 0
+	StrRecvStopAtHole StreamRecvFlag = C.VIR_STREAM_RECV_STOP_AT_HOLE
+)
+
+// ErrStreamHole is returned by RecvFlags, when StrRecvStopAtHole is used, if
+// the stream is currently in a hole. RecvHole should be called to get the
+// size of the hole.
+var ErrStreamHole = errors.New("stream is in a hole")
+
+// StreamSourceFunc provides the data to be sent by SparseSendAll. It must fill
+// "data" and return how many bytes were written to it, or zero on end-of-file.
+type StreamSourceFunc func(data []byte) (int, error)
+
+// StreamSourceHoleFunc is called by SparseSendAll to check whether the source
+// is currently in a data section ("inData" = true) or in a hole, and how many
+// bytes are left in that section ("length"). At end-of-file, it must return
+// false and zero.
+type StreamSourceHoleFunc func() (inData bool, length int64, err error)
+
+// StreamSourceSkipFunc is called by SparseSendAll after a hole of "length"
+// bytes is sent, so the source moves past it.
+type StreamSourceSkipFunc func(length int64) error
+
+// StreamSinkFunc consumes the data received by SparseRecvAll. It returns how
+// many bytes of "data" were consumed.
+type StreamSinkFunc func(data []byte) (int, error)
+
+// StreamSinkHoleFunc is called by SparseRecvAll when a hole of "length" bytes
+// is received.
+type StreamSinkHoleFunc func(length int64) error
+
+// SendHole sends a hole of "length" bytes to the stream. The stream must have
+// been set up for a sparse transfer (e.g. by UploadSparse).
+func (str Stream) SendHole(length int64) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	str.log.Printf("sending hole of %v bytes to stream...\n", length)
+	cRet := C.virStreamSendHole(str.virStream, C.longlong(length), 0)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return err
+	}
+
+	str.log.Println("hole sent")
+
+	return nil
+}
+
+// RecvHole receives the size of the hole the stream is currently in. It should
+// be called after RecvFlags returns ErrStreamHole.
+func (str Stream) RecvHole() (int64, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	var cLength C.longlong
+
+	str.log.Println("receiving hole from stream...")
+	cRet := C.virStreamRecvHole(str.virStream, &cLength, 0)
+	ret := int32(cRet)
+
+	if ret == -1 {
+		err := LastError()
+		call.error(err)
+		return 0, err
+	}
+
+	length := int64(cLength)
+	str.log.Printf("hole of %v bytes received\n", length)
+
+	return length, nil
+}
+
+// RecvFlags works like Read, but the behaviour can be changed by "flags". If
+// StrRecvStopAtHole is used, it stops receiving data at the start of a hole,
+// and returns ErrStreamHole if the stream is already in one.
+func (str Stream) RecvFlags(data []byte, flags StreamRecvFlag) (int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer runtime.KeepAlive(str.ref)
+	call := str.log.startCall()
+	defer call.finish()
+
+	dataLen := len(data)
+	if dataLen == 0 {
+		return 0, nil
+	}
+
+	// "data" holds no Go pointers, so it can be handed to libvirt directly
+	cData := (*C.char)(unsafe.Pointer(&data[0]))
+
+	str.log.with(Field{FieldFlags, flags}).Printf("receiving %v bytes from stream...\n", dataLen)
+	cRet := C.virStreamRecvFlags(str.virStream, cData, C.size_t(dataLen), C.uint(flags))
+	ret := int32(cRet)
+
+	if ret == -3 {
+		str.log.Println("stream is in a hole")
+		return 0, ErrStreamHole
+	}
+
+	if ret == -2 {
+		str.log.Println("stream operation would block")
+		return 0, ErrWouldBlock
+	}
+
+	if ret < 0 {
+		err := LastError()
+		call.error(err)
+		return 0, err
+	}
+
+	str.log.Printf("%v bytes received\n", ret)
+
+	if ret == 0 {
+		return 0, io.EOF
+	}
+
+	return int(ret), nil
+}
+
+// SparseSendAll sends all the data provided by "handler" to the stream,
+// preserving the holes reported by "holeHandler" instead of sending them as
+// zeroes. "skipHandler" is called after every hole is sent. The stream is
+// aborted if anything fails; otherwise, Finish must be called afterwards.
+func (str Stream) SparseSendAll(handler StreamSourceFunc, holeHandler StreamSourceHoleFunc, skipHandler StreamSourceSkipFunc) error {
+	cData, data := newStreamBuffer()
+	defer C.free(unsafe.Pointer(cData))
+
+	fail := func(err error) error {
+		str.Abort()
+		return err
+	}
+
+	var dataLen int64
+
+	for {
+		if dataLen == 0 {
+			inData, length, err := holeHandler()
+			if err != nil {
+				return fail(err)
+			}
+
+			if !inData && length > 0 {
+				if err = str.SendHole(length); err != nil {
+					return fail(err)
+				}
+
+				if err = skipHandler(length); err != nil {
+					return fail(err)
+				}
+
+				continue
+			}
+
+			dataLen = length
+		}
+
+		want := int64(len(data))
+		if want > dataLen {
+			want = dataLen
+		}
+
+		if want == 0 {
+			return nil
+		}
+
+		got, err := handler(data[:want])
+		if err != nil {
+			return fail(err)
+		}
+
+		if got == 0 {
+			return nil
+		}
+
+		for sent := 0; sent < got; {
+			n, err := str.send((*C.char)(unsafe.Pointer(&data[sent])), got-sent)
+			if err != nil {
+				return fail(err)
+			}
+
+			sent += n
+		}
+
+		dataLen -= int64(got)
+	}
+}
+
+// SparseRecvAll receives all the data from the stream and passes it to
+// "handler", reporting the holes to "holeHandler" instead of receiving them as
+// zeroes. The stream is aborted if anything fails; otherwise, Finish must be
+// called afterwards.
+func (str Stream) SparseRecvAll(handler StreamSinkFunc, holeHandler StreamSinkHoleFunc) error {
+	data := make([]byte, streamChunkSize)
+
+	fail := func(err error) error {
+		str.Abort()
+		return err
+	}
+
+	for {
+		got, err := str.RecvFlags(data, StrRecvStopAtHole)
+		if err == ErrStreamHole {
+			length, err := str.RecvHole()
+			if err != nil {
+				return fail(err)
+			}
+
+			if err = holeHandler(length); err != nil {
+				return fail(err)
+			}
+
+			continue
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fail(err)
+		}
+
+		for offset := 0; offset < got; {
+			n, err := handler(data[offset:got])
+			if err != nil {
+				return fail(err)
+			}
+
+			if n == 0 {
+				return fail(io.ErrShortWrite)
+			}
+
+			offset += n
+		}
+	}
+}
+
+// sparseFile detects the data sections and holes of a local file, from its
+// current offset, so it can be transferred through a sparse stream.
+type sparseFile struct {
+	file     *os.File
+	progress TransferProgressFunc
+	done     uint64
+}
+
+// newSparseFile returns a sparseFile for "f", or nil if "f" isn't a regular
+// file which supports looking up holes.
+func newSparseFile(f *os.File, progress TransferProgressFunc) *sparseFile {
+	if !seekHoleSupported {
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	return &sparseFile{file: f, progress: progress}
+}
+
+// report calls the progress function, if any, after "n" more bytes (of data
+// or holes) are transferred.
+func (sf *sparseFile) report(n int64) {
+	sf.done += uint64(n)
+
+	if sf.progress != nil {
+		sf.progress(sf.done)
+	}
+}
+
+// Read implements StreamSourceFunc.
+func (sf *sparseFile) Read(data []byte) (int, error) {
+	n, err := sf.file.Read(data)
+	sf.report(int64(n))
+
+	if err == io.EOF {
+		return n, nil
+	}
+
+	return n, err
+}
+
+// Write implements StreamSinkFunc.
+func (sf *sparseFile) Write(data []byte) (int, error) {
+	n, err := sf.file.Write(data)
+	sf.report(int64(n))
+
+	return n, err
+}
+
+// Section implements StreamSourceHoleFunc. The file offset is left unchanged.
+func (sf *sparseFile) Section() (bool, int64, error) {
+	cur, err := sf.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, 0, err
+	}
+
+	end, err := sf.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if cur >= end {
+		// end-of-file
+		_, err = sf.file.Seek(cur, io.SeekStart)
+		return false, 0, err
+	}
+
+	inData := true
+
+	next, err := sf.file.Seek(cur, seekData)
+	if err != nil {
+		// there's no more data after "cur": the file ends with a hole
+		next, inData = end, false
+	} else if next > cur {
+		inData = false
+	} else if next, err = sf.file.Seek(cur, seekHole); err != nil {
+		return false, 0, err
+	}
+
+	if _, err = sf.file.Seek(cur, io.SeekStart); err != nil {
+		return false, 0, err
+	}
+
+	return inData, next - cur, nil
+}
+
+// Hole implements StreamSinkHoleFunc. The file may already have data where the
+// hole is received, so that part is punched out (or overwritten with zeroes,
+// if that's not possible) before moving past the hole.
+func (sf *sparseFile) Hole(length int64) error {
+	cur, err := sf.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	info, err := sf.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	total := length
+
+	if stale := info.Size() - cur; stale > 0 {
+		if stale > length {
+			stale = length
+		}
+
+		if err = punchHole(sf.file, cur, stale); err != nil {
+			if err = writeZeroes(sf.file, stale); err != nil {
+				return err
+			}
+
+			length -= stale
+		}
+	}
+
+	if _, err = sf.file.Seek(length, io.SeekCurrent); err != nil {
+		return err
+	}
+
+	sf.report(total)
+
+	return nil
+}
+
+// writeZeroes writes "length" zeroes to "w".
+func writeZeroes(w io.Writer, length int64) error {
+	zeroes := make([]byte, streamChunkSize)
+
+	for length > 0 {
+		n := int64(len(zeroes))
+		if n > length {
+			n = length
+		}
+
+		if _, err := w.Write(zeroes[:n]); err != nil {
+			return err
+		}
+
+		length -= n
+	}
+
+	return nil
+}
+
+// Skip implements StreamSourceSkipFunc.
+func (sf *sparseFile) Skip(length int64) error {
+	if _, err := sf.file.Seek(length, io.SeekCurrent); err != nil {
+		return err
+	}
+
+	sf.report(length)
+
+	return nil
+}
+
+// Close extends the file up to its current offset, in case it ends with a
+// hole which was skipped.
+func (sf *sparseFile) Close() error {
+	cur, err := sf.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	info, err := sf.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() < cur {
+		return sf.file.Truncate(cur)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package libvirt
+
+import (
+	"os"
+	"syscall"
+)
+
+// The "whence" values used to look up data and holes in a file with Seek.
+const (
+	seekData = 3 // SEEK_DATA
+	seekHole = 4 // SEEK_HOLE
+)
+
+// seekHoleSupported tells whether seekData and seekHole can be used.
+const seekHoleSupported = true
+
+// punchHole deallocates "length" bytes of "f" starting at "offset", which then
+// read as zeroes, without changing the file size.
+func punchHole(f *os.File, offset int64, length int64) error {
+	const mode = 0x01 | 0x02 // FALLOC_FL_KEEP_SIZE | FALLOC_FL_PUNCH_HOLE
+
+	return syscall.Fallocate(int(f.Fd()), mode, offset, length)
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package libvirt
+
+import (
+	"errors"
+	"os"
+)
+
+// The "whence" values used to look up data and holes in a file with Seek. They
+// aren't portable, so they're only used on Linux.
+const (
+	seekData = -1
+	seekHole = -1
+)
+
+// seekHoleSupported tells whether seekData and seekHole can be used.
+const seekHoleSupported = false
+
+// errPunchHoleUnsupported is returned by punchHole.
+var errPunchHoleUnsupported = errors.New("punching holes is not supported")
+
+// punchHole isn't supported outside Linux, so the caller must write zeroes
+// instead.
+func punchHole(f *os.File, offset int64, length int64) error {
+	return errPunchHoleUnsupported
+}
@@ -1,7 +1,14 @@
 package libvirt
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/cd1/utils-golang"
 )
 
 func TestStreamAbort(t *testing.T) {
@@ -25,3 +32,62 @@ func TestStreamRef(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestStreamNonBlocking(t *testing.T) {
+	if err := StartEventLoop(); err != nil {
+		t.Fatal(err)
+	}
+
+	env := newTestEnvironment(t).withStorageVolume()
+	defer env.cleanUp()
+
+	data := utils.RandomString()
+	dataLen := uint64(len(data))
+
+	if err := env.vol.UploadFrom(context.Background(), strings.NewReader(data), 0, dataLen, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	str, err := env.conn.NewStream(StrNonBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer str.Free()
+
+	if err = env.vol.Download(str, 0, dataLen); err != nil {
+		t.Fatal(err)
+	}
+
+	nb, err := str.NonBlocking()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	chunk := make([]byte, 1024)
+
+	for {
+		n, err := nb.Read(ctx, chunk)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf.Write(chunk[:n])
+	}
+
+	if err = str.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	if dl := buf.String(); dl != data {
+		t.Errorf("unexpected downloaded content; got=%v, want=%v", dl, data)
+	}
+}